@@ -0,0 +1,56 @@
+// authz/listener.go
+package authz
+
+import (
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Listener subscribes to a Postgres LISTEN/NOTIFY channel (fired by a trigger
+// on public.users, e.g. `NOTIFY user_role_changes, NEW.id::text`) and
+// invalidates the matching RoleResolver cache entry the moment a row
+// changes, instead of waiting out cacheTTL.
+type Listener struct {
+	listener *pq.Listener
+}
+
+// StartListener opens a dedicated connection to databaseURL and begins
+// listening on channel in a background goroutine. The returned Listener must
+// be closed (via Close) on shutdown; a failure to connect returns an error so
+// the caller can decide whether that's fatal or merely means cache entries
+// fall back to expiring via cacheTTL.
+func StartListener(databaseURL, channel string, resolver RoleResolver) (*Listener, error) {
+	reportProblem := func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("authz: listener event %v: %v", ev, err)
+		}
+	}
+
+	pqListener := pq.NewListener(databaseURL, 10*time.Second, time.Minute, reportProblem)
+	if err := pqListener.Listen(channel); err != nil {
+		pqListener.Close()
+		return nil, err
+	}
+
+	l := &Listener{listener: pqListener}
+	go l.run(resolver)
+	return l, nil
+}
+
+func (l *Listener) run(resolver RoleResolver) {
+	for notification := range l.listener.Notify {
+		if notification == nil {
+			// nil notification: the driver lost and re-established the
+			// connection: any cache entry updated during the gap only
+			// changed correctness for the rest of its TTL, not forever.
+			continue
+		}
+		resolver.Invalidate(notification.Extra)
+	}
+}
+
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
@@ -0,0 +1,167 @@
+// authz/resolver.go
+package authz
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RoleResolver answers "is this user an admin?" for AdminMiddleware and the
+// login/refresh paths, without every caller issuing its own REST round trip
+// to public.users. Invalidate lets a caller (typically a Listener) evict a
+// stale cache entry the moment the underlying row changes, rather than
+// waiting out cacheTTL.
+type RoleResolver interface {
+	IsAdmin(ctx context.Context, userID string) (bool, error)
+	Invalidate(userID string)
+}
+
+const (
+	cacheTTL      = 5 * time.Minute
+	cacheCapacity = 10000
+)
+
+// restClient is the sliver of SupabaseAuthService's REST client SupabaseResolver
+// needs. Declared locally (rather than importing the service package) so
+// authz has no dependency on service - service depends on authz instead,
+// satisfying this interface structurally.
+type restClient interface {
+	MakeRestRequest(method, endpoint string, body interface{}, accessToken string) (*http.Response, error)
+}
+
+type cacheEntry struct {
+	userID    string
+	isAdmin   bool
+	expiresAt time.Time
+}
+
+// roleCache is a fixed-capacity LRU keyed by user id, with a TTL on top so a
+// row that changes without a matching NOTIFY (e.g. Listener temporarily
+// disconnected) still can't stay wrong for more than cacheTTL.
+type roleCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element // userID -> element wrapping *cacheEntry
+	order    *list.List               // front = most recently used
+}
+
+func newRoleCache(capacity int, ttl time.Duration) *roleCache {
+	return &roleCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *roleCache) get(userID string) (isAdmin bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.entries[userID]
+	if !found {
+		return false, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, userID)
+		return false, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.isAdmin, true
+}
+
+func (c *roleCache) set(userID string, isAdmin bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.entries[userID]; found {
+		elem.Value.(*cacheEntry).isAdmin = isAdmin
+		elem.Value.(*cacheEntry).expiresAt = time.Now().Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	entry := &cacheEntry{userID: userID, isAdmin: isAdmin, expiresAt: time.Now().Add(c.ttl)}
+	elem := c.order.PushFront(entry)
+	c.entries[userID] = elem
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).userID)
+		}
+	}
+}
+
+func (c *roleCache) invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.entries[userID]; found {
+		c.order.Remove(elem)
+		delete(c.entries, userID)
+	}
+}
+
+// SupabaseResolver implements RoleResolver by querying public.users through
+// Supabase's PostgREST endpoint with the service role key, caching results in
+// an in-memory LRU+TTL cache so login, refresh, and admin checks stop paying
+// a REST round trip on every request.
+type SupabaseResolver struct {
+	client         restClient
+	serviceRoleKey string
+	cache          *roleCache
+}
+
+func NewSupabaseResolver(client restClient, serviceRoleKey string) *SupabaseResolver {
+	return &SupabaseResolver{
+		client:         client,
+		serviceRoleKey: serviceRoleKey,
+		cache:          newRoleCache(cacheCapacity, cacheTTL),
+	}
+}
+
+func (r *SupabaseResolver) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+
+	if isAdmin, ok := r.cache.get(userID); ok {
+		return isAdmin, nil
+	}
+
+	resp, err := r.client.MakeRestRequest("GET", "/users?id=eq."+userID+"&select=is_admin", nil, r.serviceRoleKey)
+	if err != nil {
+		return false, fmt.Errorf("looking up is_admin for user %s: %w", userID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("looking up is_admin for user %s: status %d", userID, resp.StatusCode)
+	}
+
+	var rows []struct {
+		IsAdmin bool `json:"is_admin"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return false, fmt.Errorf("decoding is_admin lookup for user %s: %w", userID, err)
+	}
+
+	isAdmin := len(rows) > 0 && rows[0].IsAdmin
+	r.cache.set(userID, isAdmin)
+	return isAdmin, nil
+}
+
+func (r *SupabaseResolver) Invalidate(userID string) {
+	r.cache.invalidate(userID)
+}
@@ -5,13 +5,18 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/Strike-Bet/betting-engine/auth-service/authz"
 	"github.com/Strike-Bet/betting-engine/auth-service/config"
 	"github.com/Strike-Bet/betting-engine/auth-service/handlers"
 	"github.com/Strike-Bet/betting-engine/auth-service/middleware"
+	"github.com/Strike-Bet/betting-engine/auth-service/middleware/ratelimit"
 	"github.com/Strike-Bet/betting-engine/auth-service/service"
+	"github.com/Strike-Bet/betting-engine/auth-service/session"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	goredis "github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -24,17 +29,72 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Create Supabase service
-	supabaseService := service.NewSupabaseAuthService(cfg)
+	// Select the LoginProvider/OAuthProvider backend. "supabase" keeps a raw
+	// client around for the handful of endpoints not yet abstracted behind
+	// the provider interfaces; "local" has no such escape hatch.
+	var (
+		loginProvider service.LoginProvider
+		oauthProvider service.OAuthProvider
+		rawSupabase   *service.SupabaseAuthService
+		roleResolver  authz.RoleResolver
+	)
+	switch cfg.AuthBackend {
+	case "local":
+		loginProvider = service.NewLocalProvider(cfg)
+	default:
+		rawSupabase = service.NewSupabaseAuthService(cfg)
+
+		supabaseResolver := authz.NewSupabaseResolver(rawSupabase, cfg.ServiceRoleKey)
+		roleResolver = supabaseResolver
+		if cfg.DatabaseURL != "" {
+			if _, err := authz.StartListener(cfg.DatabaseURL, "user_role_changes", supabaseResolver); err != nil {
+				log.Printf("Warning: failed to start authz listener, is_admin cache entries will only expire on their own TTL: %v", err)
+			}
+		}
+
+		supabaseProvider := service.NewSupabaseProvider(rawSupabase, roleResolver)
+		loginProvider = supabaseProvider
+		oauthProvider = supabaseProvider
+	}
+
+	// Build a registry on top of the default backend so AuthHandler and
+	// OAuthHandler can resolve a different backend per request (via
+	// resolveProviderName), e.g. for serving a second, OIDC-backed tenant
+	// from the same deployment.
+	providers := service.NewProviderRegistry(cfg.AuthBackend)
+	providers.RegisterLogin(cfg.AuthBackend, loginProvider)
+	if oauthProvider != nil {
+		providers.RegisterOAuth(cfg.AuthBackend, oauthProvider)
+	}
+	if cfg.OIDCIssuerURL != "" {
+		providers.RegisterOAuth("oidc", service.NewOIDCProvider(cfg))
+	}
+
+	// WebAuthn (passkey) second factor is only wired up against the Supabase
+	// backend, since credentials are persisted via its REST API.
+	var webauthnService *service.WebAuthnService
+	if rawSupabase != nil {
+		webauthnService, err = service.NewWebAuthnService(cfg, rawSupabase)
+		if err != nil {
+			log.Fatalf("configuring webauthn: %v", err)
+		}
+	}
 
 	// Create handlers
-	authHandler := handlers.NewAuthHandler(supabaseService)
-	userHandler := handlers.NewUserHandler(supabaseService)
-	adminHandler := handlers.NewAdminHandler(supabaseService)
-	oauthHandler := handlers.NewOAuthHandler(supabaseService)
+	authHandler := handlers.NewAuthHandler(providers, rawSupabase, webauthnService)
+	userHandler := handlers.NewUserHandler(loginProvider, rawSupabase)
+	adminHandler := handlers.NewAdminHandler(loginProvider, rawSupabase)
+	oauthHandler := handlers.NewOAuthHandler(providers, rawSupabase, session.NewStore(cfg))
+	oauthServerService := service.NewOAuthServerService(cfg)
+	oauthServerHandler := handlers.NewOAuthServerHandler(oauthServerService)
+	mfaHandler := handlers.NewMFAHandler(service.NewMFAService(cfg))
+	webauthnHandler := handlers.NewWebAuthnHandler(webauthnService)
+	openIDHandler := handlers.NewOpenIDHandler(cfg, oauthServerService, roleResolver)
+
+	rlManager := newRateLimitManager(cfg)
 
 	// Setup routes with middleware
-	router := setupRoutes(cfg, authHandler, userHandler, adminHandler, oauthHandler)
+	router := setupRoutes(cfg, rlManager, roleResolver, authHandler, userHandler, adminHandler, oauthHandler, oauthServerHandler, mfaHandler, webauthnHandler, openIDHandler)
 
 	log.Printf("Supabase Auth Service starting on port %s", cfg.Port)
 	log.Printf("Supabase URL: %s", cfg.SupabaseURL)
@@ -43,33 +103,97 @@ func main() {
 	log.Fatal(http.ListenAndServe(":"+cfg.Port, router))
 }
 
+// newRateLimitManager builds the ratelimit.Manager shared by every route:
+// an in-memory bucket store by default, or a Redis-backed one (shared across
+// replicas) when cfg.RateLimitBackend is "redis". Requests bearing the
+// service role key bypass limiting entirely, matching AdminMiddleware's
+// treatment of that key elsewhere.
+func newRateLimitManager(cfg *config.Config) *ratelimit.Manager {
+	var limiter ratelimit.Limiter
+	if cfg.RateLimitBackend == "redis" {
+		opts, err := goredis.ParseURL(cfg.RateLimitRedisURL)
+		if err != nil {
+			log.Fatalf("invalid RATE_LIMIT_REDIS_URL: %v", err)
+		}
+		limiter = ratelimit.NewRedisLimiter(goredis.NewClient(opts))
+	} else {
+		limiter = ratelimit.NewInMemoryLimiter()
+	}
+
+	bypass := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+cfg.ServiceRoleKey && cfg.ServiceRoleKey != ""
+	}
+
+	return ratelimit.NewManager(limiter, bypass)
+}
+
+// routeLimits maps each credential-stuffing-prone endpoint to its own
+// RouteLimit, keyed by submitted email combined with IP (see KeyByJSONField):
+// one bucket per (email, IP) pair, so requests spread across accounts or
+// across source IPs are not limited by these per-route buckets.
+// "default", keyed by IP alone, covers every route via the global middleware
+// chain.
+func routeLimits(cfg *config.Config) map[string]ratelimit.RouteLimit {
+	hops := cfg.TrustedProxyHops
+	return map[string]ratelimit.RouteLimit{
+		"default":    {RPS: cfg.RateLimitGeneralRPS, Burst: cfg.RateLimitGeneralBurst, KeyFunc: ratelimit.KeyByIP(hops)},
+		"/token":     {RPS: cfg.RateLimitTokenRPS, Burst: cfg.RateLimitTokenBurst, KeyFunc: ratelimit.KeyByJSONField(hops, "email")},
+		"/signup":    {RPS: cfg.RateLimitSignupRPS, Burst: cfg.RateLimitSignupBurst, KeyFunc: ratelimit.KeyByJSONField(hops, "email")},
+		"/recover":   {RPS: cfg.RateLimitRecoverRPS, Burst: cfg.RateLimitRecoverBurst, KeyFunc: ratelimit.KeyByJSONField(hops, "email")},
+		"/otp":       {RPS: cfg.RateLimitOTPRPS, Burst: cfg.RateLimitOTPBurst, KeyFunc: ratelimit.KeyByJSONField(hops, "email")},
+		"/magiclink": {RPS: cfg.RateLimitMagicLinkRPS, Burst: cfg.RateLimitMagicLinkBurst, KeyFunc: ratelimit.KeyByJSONField(hops, "email")},
+	}
+}
+
 func setupRoutes(
 	cfg *config.Config,
+	rlManager *ratelimit.Manager,
+	roleResolver authz.RoleResolver,
 	authHandler *handlers.AuthHandler,
 	userHandler *handlers.UserHandler,
 	adminHandler *handlers.AdminHandler,
 	oauthHandler *handlers.OAuthHandler,
+	oauthServerHandler *handlers.OAuthServerHandler,
+	mfaHandler *handlers.MFAHandler,
+	webauthnHandler *handlers.WebAuthnHandler,
+	openIDHandler *handlers.OpenIDHandler,
 ) *mux.Router {
 	router := mux.NewRouter()
+	limits := routeLimits(cfg)
 
 	// Apply global middleware
-	router.Use(middleware.CORSMiddleware())
-	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.CORSMiddleware(cfg, nil))
+	router.Use(middleware.LoggingMiddleware(cfg))
 	router.Use(middleware.SecurityHeadersMiddleware())
 	router.Use(middleware.JSONContentTypeMiddleware())
-	router.Use(middleware.RateLimitMiddleware())
+	router.Use(rlManager.Middleware("default", limits["default"]))
 
-	// Health check endpoint (unprotected)
+	// Health check and metrics endpoints (unprotected)
 	router.HandleFunc("/health", handleHealth).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
 	// Public auth endpoints (no authentication required)
-	setupPublicRoutes(router, authHandler, oauthHandler)
+	setupPublicRoutes(router, rlManager, limits, authHandler, oauthHandler)
+
+	// OIDC discovery/JWKS (public) and /userinfo (requires valid JWT token)
+	setupOpenIDRoutes(router, cfg, openIDHandler)
 
 	// Protected user endpoints (requires valid JWT token)
 	setupProtectedRoutes(router, cfg, userHandler)
 
-	// Admin endpoints (requires service role or admin privileges)
-	setupAdminRoutes(router, cfg, adminHandler)
+	// MFA enrollment/challenge/verify endpoints (requires valid JWT token)
+	setupMFARoutes(router, cfg, mfaHandler)
+
+	// WebAuthn (passkey) registration (requires valid JWT token) and login
+	// (public, gated on the mfa_token /token issues)
+	setupWebAuthnRoutes(router, cfg, webauthnHandler)
+
+	// Admin endpoints (requires service role or admin privileges, and a
+	// verified second factor)
+	setupAdminRoutes(router, cfg, roleResolver, adminHandler, oauthServerHandler)
+
+	// This service's own OAuth2 authorization server (authorization code + PKCE)
+	setupOAuthServerRoutes(router, cfg, oauthServerHandler)
 
 	// Debug endpoints (only in development)
 	if cfg.Environment == "development" {
@@ -79,25 +203,55 @@ func setupRoutes(
 	return router
 }
 
-func setupPublicRoutes(router *mux.Router, authHandler *handlers.AuthHandler, oauthHandler *handlers.OAuthHandler) {
+// setupPublicRoutes wires the unauthenticated auth endpoints. The five
+// credential-stuffing-prone ones (/token, /signup, /recover, /otp,
+// /magiclink) each get their own tighter RouteLimit from limits, layered on
+// top of the router-wide default bucket rlManager.Middleware("default", ...)
+// already applies; the rest rely on that default alone.
+func setupPublicRoutes(router *mux.Router, rlManager *ratelimit.Manager, limits map[string]ratelimit.RouteLimit, authHandler *handlers.AuthHandler, oauthHandler *handlers.OAuthHandler) {
 	// Public auth endpoints
 	router.HandleFunc("/settings", authHandler.HandleSettings).Methods("GET")
-	router.HandleFunc("/signup", authHandler.HandleSignup).Methods("POST")
-	router.HandleFunc("/token", authHandler.HandleToken).Methods("POST")
+	router.Handle("/signup", rlManager.Middleware("/signup", limits["/signup"])(http.HandlerFunc(authHandler.HandleSignup))).Methods("POST")
+	router.Handle("/token", rlManager.Middleware("/token", limits["/token"])(http.HandlerFunc(authHandler.HandleToken))).Methods("POST")
 	router.HandleFunc("/verify", authHandler.HandleVerify).Methods("GET", "POST")
 	router.HandleFunc("/resend", authHandler.HandleResend).Methods("POST")
-	router.HandleFunc("/recover", authHandler.HandleRecover).Methods("POST")
-	router.HandleFunc("/magiclink", authHandler.HandleMagicLink).Methods("POST")
-	router.HandleFunc("/otp", authHandler.HandleOTP).Methods("POST")
+	router.Handle("/recover", rlManager.Middleware("/recover", limits["/recover"])(http.HandlerFunc(authHandler.HandleRecover))).Methods("POST")
+	router.Handle("/magiclink", rlManager.Middleware("/magiclink", limits["/magiclink"])(http.HandlerFunc(authHandler.HandleMagicLink))).Methods("POST")
+	router.Handle("/otp", rlManager.Middleware("/otp", limits["/otp"])(http.HandlerFunc(authHandler.HandleOTP))).Methods("POST")
 
 	// OAuth endpoints
 	router.HandleFunc("/authorize", oauthHandler.HandleAuthorize).Methods("GET")
 	router.HandleFunc("/callback", oauthHandler.HandleCallback).Methods("GET")
+	router.HandleFunc("/oauth/session/logout", oauthHandler.HandleSessionLogout).Methods("POST")
 
 	// Handle all OPTIONS requests for CORS preflight
 	router.PathPrefix("/").HandlerFunc(handleOptions).Methods("OPTIONS")
 }
 
+// setupOpenIDRoutes wires the native OIDC discovery document and JWKS
+// (genuinely public, per the OIDC spec) plus /userinfo, which requires the
+// same Bearer access token as the other protected routes.
+func setupOpenIDRoutes(router *mux.Router, cfg *config.Config, openIDHandler *handlers.OpenIDHandler) {
+	router.HandleFunc("/.well-known/openid-configuration", openIDHandler.HandleDiscovery).Methods("GET")
+	router.HandleFunc("/.well-known/jwks.json", openIDHandler.HandleJWKS).Methods("GET")
+
+	userinfoRoutes := router.PathPrefix("").Subrouter()
+	userinfoRoutes.Use(middleware.AuthMiddleware(cfg))
+	userinfoRoutes.HandleFunc("/userinfo", openIDHandler.HandleUserInfo).Methods("GET")
+}
+
+// setupOAuthServerRoutes wires this service's own OAuth2 authorization server.
+// /oauth/authorize requires an existing first-party session (AuthMiddleware);
+// /oauth/token and /oauth/revoke are public per RFC 6749/7009.
+func setupOAuthServerRoutes(router *mux.Router, cfg *config.Config, oauthServerHandler *handlers.OAuthServerHandler) {
+	authorizeRoutes := router.PathPrefix("").Subrouter()
+	authorizeRoutes.Use(middleware.AuthMiddleware(cfg))
+	authorizeRoutes.HandleFunc("/oauth/authorize", oauthServerHandler.HandleAuthorize).Methods("GET")
+
+	router.HandleFunc("/oauth/token", oauthServerHandler.HandleToken).Methods("POST")
+	router.HandleFunc("/oauth/revoke", oauthServerHandler.HandleRevoke).Methods("POST")
+}
+
 func setupProtectedRoutes(router *mux.Router, cfg *config.Config, userHandler *handlers.UserHandler) {
 	// Protected user endpoints (requires valid JWT token)
 	protectedUser := router.PathPrefix("").Subrouter()
@@ -109,10 +263,39 @@ func setupProtectedRoutes(router *mux.Router, cfg *config.Config, userHandler *h
 	protectedUser.HandleFunc("/logout", userHandler.HandleLogout).Methods("POST")
 }
 
-func setupAdminRoutes(router *mux.Router, cfg *config.Config, adminHandler *handlers.AdminHandler) {
-	// Admin endpoints (requires service role or admin privileges)
+// setupMFARoutes wires TOTP factor enrollment/challenge/verify. Enrollment
+// and verification only require a first-party session; verification is what
+// grants the AAL2 step-up token that RequireAAL2 checks for elsewhere.
+func setupMFARoutes(router *mux.Router, cfg *config.Config, mfaHandler *handlers.MFAHandler) {
+	mfaRoutes := router.PathPrefix("").Subrouter()
+	mfaRoutes.Use(middleware.AuthMiddleware(cfg))
+
+	mfaRoutes.HandleFunc("/factors", mfaHandler.HandleEnroll).Methods("POST")
+	mfaRoutes.HandleFunc("/factors", mfaHandler.HandleListFactors).Methods("GET")
+	mfaRoutes.HandleFunc("/factors/{id}", mfaHandler.HandleDeleteFactor).Methods("DELETE")
+	mfaRoutes.HandleFunc("/factors/{id}/challenge", mfaHandler.HandleChallenge).Methods("POST")
+	mfaRoutes.HandleFunc("/factors/{id}/verify", mfaHandler.HandleVerify).Methods("POST")
+}
+
+// setupWebAuthnRoutes wires passkey registration behind AuthMiddleware and
+// the public login ceremony that redeems the mfa_token AuthHandler.HandleToken
+// hands back once it sees a user has enrolled credentials.
+func setupWebAuthnRoutes(router *mux.Router, cfg *config.Config, webauthnHandler *handlers.WebAuthnHandler) {
+	registerRoutes := router.PathPrefix("").Subrouter()
+	registerRoutes.Use(middleware.AuthMiddleware(cfg))
+	registerRoutes.HandleFunc("/webauthn/register/begin", webauthnHandler.HandleRegisterBegin).Methods("POST")
+	registerRoutes.HandleFunc("/webauthn/register/finish", webauthnHandler.HandleRegisterFinish).Methods("POST")
+
+	router.HandleFunc("/webauthn/login/begin", webauthnHandler.HandleLoginBegin).Methods("POST")
+	router.HandleFunc("/webauthn/login/finish", webauthnHandler.HandleLoginFinish).Methods("POST")
+}
+
+func setupAdminRoutes(router *mux.Router, cfg *config.Config, roleResolver authz.RoleResolver, adminHandler *handlers.AdminHandler, oauthServerHandler *handlers.OAuthServerHandler) {
+	// Admin endpoints (requires service role or admin privileges, and - for
+	// non-service-role callers - a verified second factor)
 	adminRoutes := router.PathPrefix("/admin").Subrouter()
-	adminRoutes.Use(middleware.AdminMiddleware(cfg))
+	adminRoutes.Use(middleware.AdminMiddleware(cfg, roleResolver))
+	adminRoutes.Use(middleware.RequireAAL2)
 
 	adminRoutes.HandleFunc("/users", adminHandler.HandleCreateUser).Methods("POST")
 	adminRoutes.HandleFunc("/users/{user_id}", adminHandler.HandleUpdateUser).Methods("PUT")
@@ -120,8 +303,16 @@ func setupAdminRoutes(router *mux.Router, cfg *config.Config, adminHandler *hand
 
 	// Invite endpoint (requires admin privileges)
 	inviteRoutes := router.PathPrefix("").Subrouter()
-	inviteRoutes.Use(middleware.AdminMiddleware(cfg))
+	inviteRoutes.Use(middleware.AdminMiddleware(cfg, roleResolver))
+	inviteRoutes.Use(middleware.RequireAAL2)
 	inviteRoutes.HandleFunc("/invite", adminHandler.HandleInvite).Methods("POST")
+
+	// OAuth client registration (requires admin privileges)
+	oauthClientRoutes := router.PathPrefix("/oauth/clients").Subrouter()
+	oauthClientRoutes.Use(middleware.AdminMiddleware(cfg, roleResolver))
+	oauthClientRoutes.HandleFunc("", oauthServerHandler.HandleCreateClient).Methods("POST")
+	oauthClientRoutes.HandleFunc("/{id}", oauthServerHandler.HandleGetClient).Methods("GET")
+	oauthClientRoutes.HandleFunc("/{id}", oauthServerHandler.HandleDeleteClient).Methods("DELETE")
 }
 
 func setupDebugRoutes(router *mux.Router, cfg *config.Config) {
@@ -2,10 +2,22 @@
 package config
 
 import (
+	"encoding/json"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
+// TrustedIssuer describes an external JWT issuer AuthMiddleware will accept
+// asymmetrically-signed (RS256/ES256) tokens from, in addition to Supabase's
+// own HS256 tokens.
+type TrustedIssuer struct {
+	Issuer   string `json:"issuer"`
+	JWKSURL  string `json:"jwks_url"`
+	Audience string `json:"audience"`
+}
+
 type Config struct {
 	SupabaseURL    string // Your Supabase project URL
 	SupabaseKey    string // Your Supabase anon key
@@ -13,6 +25,107 @@ type Config struct {
 	JWTSecret      string // JWT secret for token validation
 	Port           string
 	Environment    string // development, staging, production
+
+	// AuthBackend selects which service.LoginProvider backs the auth
+	// endpoints: "supabase" (default) or "local" (in-memory bcrypt store,
+	// useful for tests, staging, and self-hosted deployments).
+	AuthBackend string
+
+	// Rate limiting. RateLimitBackend selects the middleware/ratelimit.Limiter:
+	// "memory" (default, single replica) or "redis" (shared across replicas,
+	// using RateLimitRedisURL). Each sensitive route gets its own RPS/Burst
+	// pair, since /token, /signup, /recover, and /otp need much tighter
+	// limits than general traffic to resist credential stuffing.
+	RateLimitBackend        string
+	RateLimitRedisURL       string
+	RateLimitGeneralRPS     float64
+	RateLimitGeneralBurst   int
+	RateLimitTokenRPS       float64
+	RateLimitTokenBurst     int
+	RateLimitSignupRPS      float64
+	RateLimitSignupBurst    int
+	RateLimitRecoverRPS     float64
+	RateLimitRecoverBurst   int
+	RateLimitOTPRPS         float64
+	RateLimitOTPBurst       int
+	RateLimitMagicLinkRPS   float64
+	RateLimitMagicLinkBurst int
+
+	// TrustedProxyHops is the number of reverse proxies between the client
+	// and this service that are trusted to append (not spoof) an entry to
+	// X-Forwarded-For. 0 (default) means X-Forwarded-For is never trusted
+	// and every rate-limit KeyFunc keys on RemoteAddr instead - a client
+	// sitting directly in front of the service could otherwise defeat every
+	// per-IP bucket by sending a different X-Forwarded-For on each request.
+	// When set, clientIP takes the address that many hops in from the right
+	// of the XFF chain, since each trusted hop appends one entry.
+	TrustedProxyHops int
+
+	// TrustedIssuers are external JWT issuers whose RS256/ES256 tokens
+	// AuthMiddleware will verify via JWKS, alongside Supabase's HS256 tokens.
+	TrustedIssuers []TrustedIssuer
+
+	// CORS
+	AllowedOrigins        []string // exact origins allowed to receive a reflected Access-Control-Allow-Origin
+	AllowedOriginPatterns []string // glob patterns (e.g. "https://*.vercel.app") for preview-deployment origins
+	AllowedHeaders        []string // request headers CORSMiddleware will echo back when asked for via Access-Control-Request-Headers
+
+	// LogRequestBodies enables (redacted) request body logging in
+	// LoggingMiddleware. Off by default since request bodies carry
+	// credentials even after redaction removes the sensitive fields.
+	LogRequestBodies bool
+
+	// SessionSecret signs and encrypts the cookie-backed sessions the
+	// browser-facing OAuth2/OIDC flow (handlers.OAuthHandler) uses to carry
+	// PKCE state across the /authorize -> IdP -> /callback redirect.
+	SessionSecret string
+
+	// Generic OIDC provider (service.OIDCProvider), registered alongside
+	// Supabase under the "oidc" name in the service.ProviderRegistry built
+	// in cmd/api/main.go. Unset (OIDCIssuerURL == "") disables it.
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+
+	// Issuer is this service's own public base URL, used as the `issuer`
+	// in its OIDC discovery document (handlers.OpenIDHandler) and as the
+	// `iss` claim on the id_tokens service.OAuthServerService mints. If
+	// unset, OpenIDHandler falls back to reconstructing one from the
+	// incoming request, which is fine for development but should be set
+	// explicitly once the service sits behind a stable public hostname.
+	Issuer string
+
+	// OIDCSigningKeyPEM is the PEM-encoded RSA private key (PKCS1 or PKCS8)
+	// service.OAuthServerService uses to sign RS256 id_tokens. It must be
+	// identical across every replica and stable across restarts, since
+	// /.well-known/jwks.json only publishes whatever key the replica that
+	// serves the request currently holds - an id_token signed by one replica
+	// has to verify against the JWKS any other replica (or a future process)
+	// returns. Left unset, OAuthServerService falls back to generating an
+	// ephemeral key per process, which is fine for local development only.
+	// OIDCSigningKeyID optionally pins the `kid` published in JWKS and
+	// embedded in minted id_tokens; left unset, it's derived deterministically
+	// from the public key so replicas sharing OIDCSigningKeyPEM agree on it
+	// without extra configuration.
+	OIDCSigningKeyPEM string
+	OIDCSigningKeyID  string
+
+	// WebAuthn (service.WebAuthnService) relying party identity. RPID must be
+	// the effective domain (no scheme/port) shared by every origin in
+	// RPOrigins - e.g. RPID "example.com" with RPOrigins
+	// ["https://app.example.com"].
+	WebAuthnRPID          string
+	WebAuthnRPDisplayName string
+	WebAuthnRPOrigins     []string
+
+	// DatabaseURL is a direct Postgres connection string (as opposed to
+	// SupabaseURL's REST endpoint), used only by authz.StartListener to
+	// LISTEN on the channel a trigger on public.users NOTIFYs when a row's
+	// is_admin changes. Unset disables the listener; authz.RoleResolver
+	// entries then simply expire on their own TTL instead of being evicted
+	// immediately.
+	DatabaseURL string
 }
 
 func Load() *Config {
@@ -23,16 +136,61 @@ func Load() *Config {
 		JWTSecret:      getEnv("SUPABASE_JWT_SECRET", ""),
 		Port:           getEnv("PORT", "8080"),
 		Environment:    getEnv("ENVIRONMENT", "development"),
-	}
+		AuthBackend:    getEnv("AUTH_BACKEND", "supabase"),
 
-	if config.SupabaseURL == "" {
-		log.Fatal("SUPABASE_URL environment variable is required")
-	}
-	if config.SupabaseKey == "" {
-		log.Fatal("SUPABASE_ANON_KEY environment variable is required")
+		RateLimitBackend:        getEnv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitRedisURL:       getEnv("RATE_LIMIT_REDIS_URL", ""),
+		RateLimitGeneralRPS:     getEnvFloat("RATE_LIMIT_GENERAL_RPS", 2),
+		RateLimitGeneralBurst:   getEnvInt("RATE_LIMIT_GENERAL_BURST", 120),
+		RateLimitTokenRPS:       getEnvFloat("RATE_LIMIT_TOKEN_RPS", 0.05),
+		RateLimitTokenBurst:     getEnvInt("RATE_LIMIT_TOKEN_BURST", 10),
+		RateLimitSignupRPS:      getEnvFloat("RATE_LIMIT_SIGNUP_RPS", 0.02),
+		RateLimitSignupBurst:    getEnvInt("RATE_LIMIT_SIGNUP_BURST", 5),
+		RateLimitRecoverRPS:     getEnvFloat("RATE_LIMIT_RECOVER_RPS", 0.01),
+		RateLimitRecoverBurst:   getEnvInt("RATE_LIMIT_RECOVER_BURST", 3),
+		RateLimitOTPRPS:         getEnvFloat("RATE_LIMIT_OTP_RPS", 0.01),
+		RateLimitOTPBurst:       getEnvInt("RATE_LIMIT_OTP_BURST", 3),
+		RateLimitMagicLinkRPS:   getEnvFloat("RATE_LIMIT_MAGICLINK_RPS", 0.01),
+		RateLimitMagicLinkBurst: getEnvInt("RATE_LIMIT_MAGICLINK_BURST", 3),
+		TrustedProxyHops:        getEnvInt("TRUSTED_PROXY_HOPS", 0),
+
+		TrustedIssuers: getEnvTrustedIssuers("TRUSTED_ISSUERS"),
+
+		AllowedOrigins:        getEnvStringSlice("ALLOWED_ORIGINS", []string{"http://localhost:3000", "https://localhost:3000"}),
+		AllowedOriginPatterns: getEnvStringSlice("ALLOWED_ORIGIN_PATTERNS", nil),
+		AllowedHeaders:        getEnvStringSlice("ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "X-Requested-With", "apikey", "Accept", "Origin"}),
+
+		LogRequestBodies: getEnvBool("LOG_REQUEST_BODIES", false),
+
+		SessionSecret: getEnv("SESSION_SECRET", ""),
+
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+
+		Issuer: getEnv("PUBLIC_ISSUER_URL", ""),
+
+		OIDCSigningKeyPEM: getEnv("OIDC_SIGNING_KEY_PEM", ""),
+		OIDCSigningKeyID:  getEnv("OIDC_SIGNING_KEY_ID", ""),
+
+		WebAuthnRPID:          getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPDisplayName: getEnv("WEBAUTHN_RP_DISPLAY_NAME", "Hoptix"),
+		WebAuthnRPOrigins:     getEnvStringSlice("WEBAUTHN_RP_ORIGINS", []string{"http://localhost:3000"}),
+
+		DatabaseURL: getEnv("DATABASE_URL", ""),
 	}
-	if config.ServiceRoleKey == "" {
-		log.Fatal("SUPABASE_SERVICE_ROLE_KEY environment variable is required")
+
+	if config.AuthBackend == "supabase" {
+		if config.SupabaseURL == "" {
+			log.Fatal("SUPABASE_URL environment variable is required")
+		}
+		if config.SupabaseKey == "" {
+			log.Fatal("SUPABASE_ANON_KEY environment variable is required")
+		}
+		if config.ServiceRoleKey == "" {
+			log.Fatal("SUPABASE_SERVICE_ROLE_KEY environment variable is required")
+		}
 	}
 	if config.JWTSecret == "" {
 		log.Fatal("SUPABASE_JWT_SECRET environment variable is required")
@@ -47,3 +205,76 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvTrustedIssuers parses TRUSTED_ISSUERS as a JSON array of
+// TrustedIssuer, e.g. `[{"issuer":"https://example.auth0.com/","jwks_url":"https://example.auth0.com/.well-known/jwks.json","audience":"my-api"}]`.
+func getEnvTrustedIssuers(key string) []TrustedIssuer {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var issuers []TrustedIssuer
+	if err := json.Unmarshal([]byte(value), &issuers); err != nil {
+		log.Printf("Warning: invalid value for %s, ignoring: %v", key, err)
+		return nil
+	}
+	return issuers
+}
+
+// getEnvStringSlice parses key as a comma-separated list, trimming whitespace
+// around each element, or returns defaultValue if key is unset.
+func getEnvStringSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s (%q), using default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s (%q), using default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s (%q), using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -12,12 +12,17 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// AdminHandler uses the LoginProvider abstraction for the two admin
+// operations it models (create user, generate link), and falls back to the
+// raw Supabase client for update-user and invite, which aren't yet part of
+// that interface.
 type AdminHandler struct {
-	service *service.SupabaseAuthService
+	login service.LoginProvider
+	raw   *service.SupabaseAuthService
 }
 
-func NewAdminHandler(service *service.SupabaseAuthService) *AdminHandler {
-	return &AdminHandler{service: service}
+func NewAdminHandler(login service.LoginProvider, raw *service.SupabaseAuthService) *AdminHandler {
+	return &AdminHandler{login: login, raw: raw}
 }
 
 // POST /admin/users - Create user (requires service role)
@@ -28,16 +33,14 @@ func (h *AdminHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/admin/users", req, true)
+	user, err := h.login.AdminCreateUser(r.Context(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "Create user failed", err.Error())
 		return
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(user)
 }
 
 // PUT /admin/users/{user_id} - Update user (requires service role)
@@ -51,8 +54,13 @@ func (h *AdminHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
 	endpoint := fmt.Sprintf("/admin/users/%s", userID)
-	resp, err := h.service.MakeRequest("PUT", endpoint, req, true)
+	resp, err := h.raw.MakeRequest("PUT", endpoint, req, true)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -72,16 +80,14 @@ func (h *AdminHandler) HandleGenerateLink(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/admin/generate_link", req, true)
+	linkResp, err := h.login.GenerateLink(r.Context(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "Generate link failed", err.Error())
 		return
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(linkResp)
 }
 
 // POST /invite - Invite a user (requires service role)
@@ -92,7 +98,12 @@ func (h *AdminHandler) HandleInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/invite", req, true)
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
+	resp, err := h.raw.MakeRequest("POST", "/invite", req, true)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
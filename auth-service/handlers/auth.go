@@ -5,22 +5,57 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/Strike-Bet/betting-engine/auth-service/service"
 	"github.com/Strike-Bet/betting-engine/auth-service/types"
 )
 
+// AuthHandler dispatches signup/token requests to a named backend in a
+// service.ProviderRegistry (resolved per-request via resolveProviderName),
+// so it isn't hard-wired to Supabase and can serve more than one tenant's
+// identity backend from a single deployment. raw is an optional escape
+// hatch to Supabase's own REST surface for endpoints (settings, resend,
+// magiclink, otp, verify-by-GET) that aren't yet part of the LoginProvider
+// interface; it is nil for backends other than Supabase, and those
+// endpoints respond 501 in that case.
 type AuthHandler struct {
-	service *service.SupabaseAuthService
+	logins   *service.ProviderRegistry
+	raw      *service.SupabaseAuthService
+	webauthn *service.WebAuthnService
 }
 
-func NewAuthHandler(service *service.SupabaseAuthService) *AuthHandler {
-	return &AuthHandler{service: service}
+func NewAuthHandler(logins *service.ProviderRegistry, raw *service.SupabaseAuthService, webauthn *service.WebAuthnService) *AuthHandler {
+	return &AuthHandler{logins: logins, raw: raw, webauthn: webauthn}
+}
+
+// resolveProviderName picks which registered backend a request should use:
+// an explicit `?provider=` query param takes precedence, falling back to the
+// first subdomain label of the request Host (e.g. "tenant1.auth.example.com"
+// -> "tenant1") for subdomain-per-tenant deployments. Either may be empty, in
+// which case ProviderRegistry falls back to its configured default.
+func resolveProviderName(r *http.Request) string {
+	if name := r.URL.Query().Get("provider"); name != "" {
+		return name
+	}
+	host := r.Host
+	if idx := strings.Index(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	if idx := strings.Index(host, "."); idx != -1 {
+		return host[:idx]
+	}
+	return ""
 }
 
 // GET /settings - Get public settings
 func (h *AuthHandler) HandleSettings(w http.ResponseWriter, r *http.Request) {
-	resp, err := h.service.MakeRequest("GET", "/settings", nil, false)
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
+	resp, err := h.raw.MakeRequest("GET", "/settings", nil, false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -34,126 +69,85 @@ func (h *AuthHandler) HandleSettings(w http.ResponseWriter, r *http.Request) {
 
 // POST /signup - Register a new user
 func (h *AuthHandler) HandleSignup(w http.ResponseWriter, r *http.Request) {
-	// Log the request for debugging
-	log.Printf("Signup request: Method=%s, ContentType=%s, Origin=%s",
-		r.Method, r.Header.Get("Content-Type"), r.Header.Get("Origin"))
-
 	var req types.SignupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("Signup request body decode error: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body", err.Error())
 		return
 	}
 
-	// Log the parsed request (without password)
-	log.Printf("Signup request parsed: Email=%s, HasPassword=%t", req.Email, req.Password != "")
+	log.Printf("Signup request: Email=%s, HasPassword=%t", req.Email, req.Password != "")
 
-	resp, err := h.service.MakeRequest("POST", "/signup", req, false)
-	if err != nil {
-		log.Printf("Supabase request error: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Internal server error",
-			"details": err.Error(),
-		})
+	login := h.logins.Login(resolveProviderName(r))
+	if login == nil {
+		writeJSONError(w, http.StatusNotImplemented, "No signup-capable auth backend configured", "")
 		return
 	}
-	defer resp.Body.Close()
 
-	log.Printf("Supabase response: Status=%d", resp.StatusCode)
+	user, err := login.Signup(r.Context(), req)
+	if err != nil {
+		log.Printf("Signup error: %v", err)
+		writeJSONError(w, http.StatusBadRequest, "Signup failed", err.Error())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(user)
 }
 
 // POST /token - OAuth2 token endpoint
 func (h *AuthHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	grantType := r.URL.Query().Get("grant_type")
 
-	if grantType == "password" {
-		// Handle password grant
-		var req struct {
-			Email    string `json:"email,omitempty"`
-			Phone    string `json:"phone,omitempty"`
-			Password string `json:"password"`
-		}
+	login := h.logins.Login(resolveProviderName(r))
+	if login == nil {
+		writeJSONError(w, http.StatusNotImplemented, "No login-capable auth backend configured", "")
+		return
+	}
+
+	switch grantType {
+	case "password":
+		var req types.LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		endpoint := "/token?grant_type=password"
-		resp, err := h.service.MakeRequest("POST", endpoint, req, false)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer resp.Body.Close()
-
-		// If login failed, return the error as-is
-		if resp.StatusCode != http.StatusOK {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(resp.StatusCode)
-			io.Copy(w, resp.Body)
-			return
+		identifier := req.Email
+		if identifier == "" {
+			identifier = req.Phone
 		}
 
-		// Parse the auth response to check admin status
-		var authResponse types.AuthResponse
-		responseBody, err := io.ReadAll(resp.Body)
+		user, authResponse, err := login.AttemptLogin(r.Context(), identifier, req.Password)
 		if err != nil {
-			log.Printf("Failed to read token response: %v", err)
-			http.Error(w, "Failed to process authentication response", http.StatusInternalServerError)
-			return
-		}
-
-		if err := json.Unmarshal(responseBody, &authResponse); err != nil {
-			log.Printf("Failed to parse token response: %v", err)
-			http.Error(w, "Failed to parse authentication response", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid login credentials", err.Error())
 			return
 		}
 
-		// Check admin status from public.users table
-		isAdmin := false
-		if authResponse.User != nil && authResponse.User.ID != "" {
-			userEndpoint := "/users?id=eq." + authResponse.User.ID + "&select=is_admin"
-			userResp, err := h.service.MakeRestRequest("GET", userEndpoint, nil, authResponse.AccessToken)
-			if err == nil {
-				defer userResp.Body.Close()
-				if userResp.StatusCode == http.StatusOK {
-					var users []struct {
-						IsAdmin bool `json:"is_admin"`
-					}
-					userBody, err := io.ReadAll(userResp.Body)
-					if err == nil {
-						if err := json.Unmarshal(userBody, &users); err == nil && len(users) > 0 {
-							isAdmin = users[0].IsAdmin
-						}
-					}
-				}
+		if h.webauthn != nil && user != nil {
+			hasCreds, err := h.webauthn.HasCredentials(user.ID)
+			if err != nil {
+				log.Printf("webauthn credential lookup failed for user %s: %v", user.ID, err)
+				writeJSONError(w, http.StatusInternalServerError, "Failed to verify MFA enrollment", err.Error())
+				return
 			}
-		}
+			if hasCreds {
+				mfaToken, err := h.webauthn.BeginPendingLogin(user.ID, authResponse)
+				if err != nil {
+					writeJSONError(w, http.StatusInternalServerError, "Failed to start MFA challenge", err.Error())
+					return
+				}
 
-		// Add is_admin to response
-		authResponse.IsAdmin = isAdmin
-		if authResponse.User != nil {
-			authResponse.User.IsAdmin = isAdmin
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(types.MFARequiredResponse{MFARequired: true, MFAToken: mfaToken})
+				return
+			}
 		}
 
-		// Return the modified response
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(authResponse)
 
-	} else if grantType == "refresh_token" {
-		// Handle refresh token grant
+	case "refresh_token":
 		var req struct {
 			RefreshToken string `json:"refresh_token"`
 		}
@@ -162,70 +156,16 @@ func (h *AuthHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		endpoint := "/token?grant_type=refresh_token"
-		resp, err := h.service.MakeRequest("POST", endpoint, req, false)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer resp.Body.Close()
-
-		// If refresh failed, return the error as-is
-		if resp.StatusCode != http.StatusOK {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(resp.StatusCode)
-			io.Copy(w, resp.Body)
-			return
-		}
-
-		// Parse the auth response to check admin status
-		var authResponse types.AuthResponse
-		responseBody, err := io.ReadAll(resp.Body)
+		_, authResponse, err := login.RefreshSession(r.Context(), req.RefreshToken)
 		if err != nil {
-			log.Printf("Failed to read refresh token response: %v", err)
-			http.Error(w, "Failed to process refresh response", http.StatusInternalServerError)
-			return
-		}
-
-		if err := json.Unmarshal(responseBody, &authResponse); err != nil {
-			log.Printf("Failed to parse refresh token response: %v", err)
-			http.Error(w, "Failed to parse refresh response", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid refresh token", err.Error())
 			return
 		}
 
-		// Check admin status from public.users table
-		isAdmin := false
-		if authResponse.User != nil && authResponse.User.ID != "" {
-			userEndpoint := "/users?id=eq." + authResponse.User.ID + "&select=is_admin"
-			userResp, err := h.service.MakeRestRequest("GET", userEndpoint, nil, authResponse.AccessToken)
-			if err == nil {
-				defer userResp.Body.Close()
-				if userResp.StatusCode == http.StatusOK {
-					var users []struct {
-						IsAdmin bool `json:"is_admin"`
-					}
-					userBody, err := io.ReadAll(userResp.Body)
-					if err == nil {
-						if err := json.Unmarshal(userBody, &users); err == nil && len(users) > 0 {
-							isAdmin = users[0].IsAdmin
-						}
-					}
-				}
-			}
-		}
-
-		// Add is_admin to response
-		authResponse.IsAdmin = isAdmin
-		if authResponse.User != nil {
-			authResponse.User.IsAdmin = isAdmin
-		}
-
-		// Return the modified response
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(authResponse)
 
-	} else {
+	default:
 		http.Error(w, "Unsupported grant type. Use 'password' or 'refresh_token'", http.StatusBadRequest)
 	}
 }
@@ -240,13 +180,18 @@ func (h *AuthHandler) HandleVerify(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AuthHandler) handleVerifyGET(w http.ResponseWriter, r *http.Request) {
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
 	// Forward all query parameters
 	endpoint := "/verify"
 	if r.URL.RawQuery != "" {
 		endpoint += "?" + r.URL.RawQuery
 	}
 
-	resp, err := h.service.MakeRequest("GET", endpoint, nil, false)
+	resp, err := h.raw.MakeRequest("GET", endpoint, nil, false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -274,27 +219,36 @@ func (h *AuthHandler) handleVerifyPOST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/verify", req, false)
+	login := h.logins.Login(resolveProviderName(r))
+	if login == nil {
+		writeJSONError(w, http.StatusNotImplemented, "No login-capable auth backend configured", "")
+		return
+	}
+
+	authResponse, err := login.VerifyOTP(r.Context(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "Verification failed", err.Error())
 		return
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(authResponse)
 }
 
 // POST /resend - Resend confirmation
 func (h *AuthHandler) HandleResend(w http.ResponseWriter, r *http.Request) {
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
 	var req map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/resend", req, false)
+	resp, err := h.raw.MakeRequest("POST", "/resend", req, false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -314,27 +268,34 @@ func (h *AuthHandler) HandleRecover(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/recover", req, false)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	login := h.logins.Login(resolveProviderName(r))
+	if login == nil {
+		writeJSONError(w, http.StatusNotImplemented, "No login-capable auth backend configured", "")
 		return
 	}
-	defer resp.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	if err := login.Recover(r.Context(), req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Recovery request failed", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
 }
 
 // POST /magiclink - Send magic link
 func (h *AuthHandler) HandleMagicLink(w http.ResponseWriter, r *http.Request) {
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
 	var req types.MagicLinkRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/magiclink", req, false)
+	resp, err := h.raw.MakeRequest("POST", "/magiclink", req, false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -348,13 +309,18 @@ func (h *AuthHandler) HandleMagicLink(w http.ResponseWriter, r *http.Request) {
 
 // POST /otp - Send OTP
 func (h *AuthHandler) HandleOTP(w http.ResponseWriter, r *http.Request) {
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
 	var req types.OTPRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	resp, err := h.service.MakeRequest("POST", "/otp", req, false)
+	resp, err := h.raw.MakeRequest("POST", "/otp", req, false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -366,3 +332,12 @@ func (h *AuthHandler) HandleOTP(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, resp.Body)
 }
 
+func writeJSONError(w http.ResponseWriter, statusCode int, message, details string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(types.ErrorResponse{
+		Code:    statusCode,
+		Message: message,
+		Details: details,
+	})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/middleware"
+	"github.com/Strike-Bet/betting-engine/auth-service/service"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+
+	"github.com/gorilla/mux"
+)
+
+// MFAHandler exposes TOTP factor enrollment, challenge, and verification
+// endpoints. All routes require an existing first-party session (AuthMiddleware).
+type MFAHandler struct {
+	mfa *service.MFAService
+}
+
+func NewMFAHandler(mfa *service.MFAService) *MFAHandler {
+	return &MFAHandler{mfa: mfa}
+}
+
+// POST /factors - enroll a new TOTP factor
+func (h *MFAHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	var req types.MFAEnrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Type == "" {
+		req.Type = "totp"
+	}
+	if req.Type != "totp" {
+		writeJSONError(w, http.StatusBadRequest, "Unsupported factor type", req.Type)
+		return
+	}
+
+	accountName := user.Email
+	if accountName == "" {
+		accountName = user.UserID
+	}
+
+	resp, err := h.mfa.Enroll(user.UserID, accountName, req.FriendlyName)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Enrollment failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GET /factors - list enrolled factors
+func (h *MFAHandler) HandleListFactors(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	factors := h.mfa.ListFactors(user.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"factors": factors})
+}
+
+// DELETE /factors/{id} - unenroll a factor
+func (h *MFAHandler) HandleDeleteFactor(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	factorID := mux.Vars(r)["id"]
+	if err := h.mfa.DeleteFactor(user.UserID, factorID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Delete failed", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /factors/{id}/challenge - issue a challenge for a factor
+func (h *MFAHandler) HandleChallenge(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	factorID := mux.Vars(r)["id"]
+	resp, err := h.mfa.Challenge(user.UserID, factorID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Challenge failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /factors/{id}/verify - verify a challenge and step up to AAL2
+func (h *MFAHandler) HandleVerify(w http.ResponseWriter, r *http.Request) {
+	factorID := mux.Vars(r)["id"]
+
+	var req types.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.FactorID = factorID
+
+	token := middleware.GetTokenFromContext(r)
+	if token == "" {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	authResp, err := h.mfa.Verify(token, req.FactorID, req.ChallengeID, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Verification failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResp)
+}
@@ -1,74 +1,158 @@
 package handlers
 
 import (
-	"io"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 
 	"github.com/Strike-Bet/betting-engine/auth-service/service"
+	"github.com/Strike-Bet/betting-engine/auth-service/session"
 )
 
+// OAuthHandler drives a browser-facing authorization-code + PKCE login flow
+// on top of a named backend in a service.ProviderRegistry (resolved
+// per-request via resolveProviderName, for multi-tenant IdP support):
+// /authorize generates state and a PKCE code_verifier, stashes them (and the
+// resolved provider name) in a signed, HTTP-only session cookie, and forwards
+// the code_challenge to the provider; /callback validates state, completes
+// against the same provider the flow started with, and starts a logged-in
+// session (or redirects to post_login_redirect). It is nil-safe: a backend
+// with no social login concept (e.g. the local username/password provider)
+// responds 501.
 type OAuthHandler struct {
-	service *service.SupabaseAuthService
+	oauths   *service.ProviderRegistry
+	raw      *service.SupabaseAuthService
+	sessions *session.Store
 }
 
-func NewOAuthHandler(service *service.SupabaseAuthService) *OAuthHandler {
-	return &OAuthHandler{service: service}
+func NewOAuthHandler(oauths *service.ProviderRegistry, raw *service.SupabaseAuthService, sessions *session.Store) *OAuthHandler {
+	return &OAuthHandler{oauths: oauths, raw: raw, sessions: sessions}
 }
 
-// GET /authorize - OAuth2 authorization
+// GET /authorize - begins the OAuth2/OIDC authorization-code + PKCE flow
 func (h *OAuthHandler) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
-	// Forward all query parameters
-	endpoint := "/authorize"
-	if r.URL.RawQuery != "" {
-		endpoint += "?" + r.URL.RawQuery
+	providerName := resolveProviderName(r)
+	oauth := h.oauths.OAuth(providerName)
+	if oauth == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
 	}
 
-	resp, err := h.service.MakeRequest("GET", endpoint, nil, false)
+	state, err := randomURLSafeString(32)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to start login flow", http.StatusInternalServerError)
+		return
+	}
+	codeVerifier, err := randomURLSafeString(32)
+	if err != nil {
+		http.Error(w, "Failed to start login flow", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
+	codeChallenge := pkceS256Challenge(codeVerifier)
 
-	// Handle redirect responses
-	if resp.StatusCode == http.StatusSeeOther || resp.StatusCode == http.StatusFound {
-		location := resp.Header.Get("Location")
-		if location != "" {
-			http.Redirect(w, r, location, resp.StatusCode)
-			return
-		}
+	postLoginRedirect := r.URL.Query().Get("post_login_redirect")
+	if err := h.sessions.SaveOAuthFlow(w, r, state, codeVerifier, postLoginRedirect, providerName); err != nil {
+		http.Error(w, "Failed to start login flow", http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	query := r.URL.Query()
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	query.Del("post_login_redirect") // local-only param, not forwarded upstream
+	// provider is NOT deleted here: resolveProviderName above already
+	// consumed it for registry selection, but for the Supabase backend it's
+	// also GoTrue's own /authorize param for picking the social IdP
+	// (?provider=google|github|...), so it still needs to reach oauth.Authorize.
+
+	redirectURL, err := oauth.Authorize(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
 }
 
-// GET /callback - OAuth2 callback
+// GET /callback - completes the authorization-code + PKCE flow
 func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
-	// Forward all query parameters
-	endpoint := "/callback"
-	if r.URL.RawQuery != "" {
-		endpoint += "?" + r.URL.RawQuery
+	wantState, codeVerifier, postLoginRedirect, providerName, ok := h.sessions.ConsumeOAuthFlow(w, r)
+	if !ok {
+		http.Error(w, "Missing or expired login flow session", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("state") != wantState {
+		http.Error(w, "State mismatch", http.StatusBadRequest)
+		return
+	}
+
+	oauth := h.oauths.OAuth(providerName)
+	if oauth == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
 	}
 
-	resp, err := h.service.MakeRequest("GET", endpoint, nil, false)
+	query := r.URL.Query()
+	query.Set("code_verifier", codeVerifier)
+
+	authResponse, err := oauth.Callback(r.Context(), query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
 
-	// Handle redirect responses
-	if resp.StatusCode == http.StatusSeeOther || resp.StatusCode == http.StatusFound {
-		location := resp.Header.Get("Location")
-		if location != "" {
-			http.Redirect(w, r, location, resp.StatusCode)
+	if authResponse.User != nil {
+		if err := h.sessions.StartAuthSession(w, r, authResponse.User.ID); err != nil {
+			http.Error(w, "Failed to start session", http.StatusInternalServerError)
 			return
 		}
 	}
 
+	if postLoginRedirect != "" {
+		http.Redirect(w, r, postLoginRedirect, http.StatusFound)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(authResponse)
+}
+
+// POST /oauth/session/logout - clears the local browser session before
+// revoking the underlying Supabase session, mirroring the afterLogoutHandler
+// pattern: local state is torn down first so a failure calling out to
+// Supabase never leaves the browser looking logged in.
+func (h *OAuthHandler) HandleSessionLogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.sessions.ClearAuthSession(w, r); err != nil {
+		http.Error(w, "Failed to clear session", http.StatusInternalServerError)
+		return
+	}
+
+	if h.raw != nil {
+		if accessToken := extractAccessToken(r); accessToken != "" {
+			if resp, err := h.raw.MakeAuthenticatedRequest("POST", "/logout", nil, accessToken); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// pkceS256Challenge computes the RFC 7636 S256 code_challenge for verifier.
+func pkceS256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomURLSafeString returns a base64url-encoded random string suitable for
+// both PKCE code_verifier (RFC 7636 requires 43-128 characters) and state.
+func randomURLSafeString(numBytes int) (string, error) {
+	buf := make([]byte, numBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/middleware"
+	"github.com/Strike-Bet/betting-engine/auth-service/service"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+
+	"github.com/gorilla/mux"
+)
+
+// OAuthServerHandler exposes this service's own OAuth2 authorization server
+// endpoints (authorization code + PKCE), as distinct from OAuthHandler, whose
+// /authorize and /callback log a browser in through an upstream provider
+// (Supabase social login or a generic OIDC issuer).
+type OAuthServerHandler struct {
+	server *service.OAuthServerService
+}
+
+func NewOAuthServerHandler(server *service.OAuthServerService) *OAuthServerHandler {
+	return &OAuthServerHandler{server: server}
+}
+
+// GET /oauth/authorize - requires a valid first-party session (AuthMiddleware);
+// mints an authorization code bound to the caller and redirects to redirect_uri
+func (h *OAuthServerHandler) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeOAuthError(w, http.StatusUnauthorized, "login_required")
+		return
+	}
+
+	q := r.URL.Query()
+	req := types.AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+
+	if req.ResponseType != "code" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_response_type")
+		return
+	}
+	if req.CodeChallenge == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request: code_challenge is required")
+		return
+	}
+
+	code, err := h.server.IssueAuthorizationCode(req, user.UserID, middleware.GetTokenFromContext(r))
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redirectURL, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request: redirect_uri is not a valid URL")
+		return
+	}
+	query := redirectURL.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectURL.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+// POST /oauth/token - exchanges an authorization code + code_verifier, or a
+// refresh_token, for a fresh access/refresh token pair
+func (h *OAuthServerHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
+	var req types.TokenExchangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var (
+		authResp *types.AuthResponse
+		err      error
+	)
+
+	switch req.GrantType {
+	case "authorization_code":
+		authResp, err = h.server.ExchangeAuthorizationCode(req)
+	case "refresh_token":
+		authResp, err = h.server.RefreshAccessToken(req.RefreshToken)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResp)
+}
+
+// POST /oauth/revoke - revokes a refresh token (RFC 7009)
+func (h *OAuthServerHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	var req types.RevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	h.server.RevokeToken(req.Token)
+	w.WriteHeader(http.StatusOK)
+}
+
+// POST /oauth/clients - registers a new OAuth client (requires AdminMiddleware)
+func (h *OAuthServerHandler) HandleCreateClient(w http.ResponseWriter, r *http.Request) {
+	var req types.ClientRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	client, err := h.server.RegisterClient(req)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(client)
+}
+
+// GET /oauth/clients/{id} - fetches a registered client (requires AdminMiddleware)
+func (h *OAuthServerHandler) HandleGetClient(w http.ResponseWriter, r *http.Request) {
+	clientID := mux.Vars(r)["id"]
+
+	client, ok := h.server.GetClient(clientID)
+	if !ok {
+		writeOAuthError(w, http.StatusNotFound, "client not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(client)
+}
+
+// DELETE /oauth/clients/{id} - deregisters a client (requires AdminMiddleware)
+func (h *OAuthServerHandler) HandleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	clientID := mux.Vars(r)["id"]
+	h.server.DeleteClient(clientID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeOAuthError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(types.ErrorResponse{
+		Code:    statusCode,
+		Message: message,
+	})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/authz"
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/Strike-Bet/betting-engine/auth-service/middleware"
+	"github.com/Strike-Bet/betting-engine/auth-service/service"
+)
+
+// OpenIDHandler exposes this service's own OIDC discovery document, JWKS,
+// and /userinfo endpoint, so standard OIDC clients (kubectl, oauth2-proxy,
+// Grafana) can integrate directly against it instead of knowing about
+// Supabase. Discovery and JWKS are genuinely public; /userinfo is wired up
+// behind AuthMiddleware in cmd/api/main.go like the other protected routes.
+type OpenIDHandler struct {
+	cfg      *config.Config
+	server   *service.OAuthServerService
+	resolver authz.RoleResolver
+}
+
+func NewOpenIDHandler(cfg *config.Config, server *service.OAuthServerService, resolver authz.RoleResolver) *OpenIDHandler {
+	return &OpenIDHandler{cfg: cfg, server: server, resolver: resolver}
+}
+
+// issuer returns cfg.Issuer if set, or reconstructs one from the request so
+// discovery still works out of the box without PUBLIC_ISSUER_URL configured.
+func (h *OpenIDHandler) issuer(r *http.Request) string {
+	if h.cfg.Issuer != "" {
+		return h.cfg.Issuer
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// GET /.well-known/openid-configuration
+func (h *OpenIDHandler) HandleDiscovery(w http.ResponseWriter, r *http.Request) {
+	issuer := h.issuer(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"password", "refresh_token", "authorization_code"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"token_endpoint_auth_methods_supported": []string{"none"},
+	})
+}
+
+// GET /.well-known/jwks.json - publishes the RSA public key OAuthServerService
+// uses to sign id_tokens. It does not (and cannot) publish a key for the
+// HS256 access/refresh tokens, which are signed with a shared secret.
+func (h *OpenIDHandler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub, kid := h.server.SigningPublicKey()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			},
+		},
+	})
+}
+
+// GET /userinfo - the standard OIDC claim set for the Bearer access token's
+// subject, read from AuthMiddleware's already-verified claims plus a
+// best-effort is_admin lookup from public.users.
+func (h *OpenIDHandler) HandleUserInfo(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "No user in context", http.StatusUnauthorized)
+		return
+	}
+
+	info := map[string]interface{}{
+		"sub":            claims.UserID,
+		"email":          claims.Email,
+		"email_verified": claims.Email != "",
+	}
+	if name, ok := claims.Metadata["name"].(string); ok {
+		info["name"] = name
+	}
+	if username, ok := claims.Metadata["preferred_username"].(string); ok {
+		info["preferred_username"] = username
+	}
+	if picture, ok := claims.Metadata["picture"].(string); ok {
+		info["picture"] = picture
+	}
+	if h.resolver != nil && claims.UserID != "" {
+		if isAdmin, err := h.resolver.IsAdmin(r.Context(), claims.UserID); err == nil {
+			info["is_admin"] = isAdmin
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
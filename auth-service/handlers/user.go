@@ -6,16 +6,21 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Strike-Bet/betting-engine/auth-service/middleware"
 	"github.com/Strike-Bet/betting-engine/auth-service/service"
 	"github.com/Strike-Bet/betting-engine/auth-service/types"
 )
 
+// UserHandler uses the LoginProvider abstraction for HandleUpdateUser, and
+// falls back to the raw Supabase client for the endpoints (get user,
+// reauthenticate, logout) that aren't yet part of that interface.
 type UserHandler struct {
-	service *service.SupabaseAuthService
+	login service.LoginProvider
+	raw   *service.SupabaseAuthService
 }
 
-func NewUserHandler(service *service.SupabaseAuthService) *UserHandler {
-	return &UserHandler{service: service}
+func NewUserHandler(login service.LoginProvider, raw *service.SupabaseAuthService) *UserHandler {
+	return &UserHandler{login: login, raw: raw}
 }
 
 // GET /user - Get current user (requires authentication)
@@ -25,8 +30,12 @@ func (h *UserHandler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
 
-	resp, err := h.service.MakeAuthenticatedRequest("GET", "/user", nil, accessToken)
+	resp, err := h.raw.MakeAuthenticatedRequest("GET", "/user", nil, accessToken)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -52,16 +61,24 @@ func (h *UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.service.MakeAuthenticatedRequest("PUT", "/user", req, accessToken)
+	// Changing the account password is sensitive enough to require a fresh
+	// MFA step-up, even though the rest of this endpoint only needs AAL1.
+	if req.Password != "" {
+		claims := middleware.GetUserFromContext(r)
+		if claims == nil || claims.AAL != "aal2" {
+			writeJSONError(w, http.StatusUnauthorized, "Password changes require a verified second factor (AAL2)", "")
+			return
+		}
+	}
+
+	user, err := h.login.UpdateUser(r.Context(), accessToken, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "Update failed", err.Error())
 		return
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(user)
 }
 
 // POST /reauthenticate - Reauthenticate user
@@ -71,8 +88,12 @@ func (h *UserHandler) HandleReauthenticate(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
+	if h.raw == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
 
-	resp, err := h.service.MakeAuthenticatedRequest("GET", "/reauthenticate", nil, accessToken)
+	resp, err := h.raw.MakeAuthenticatedRequest("GET", "/reauthenticate", nil, accessToken)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -91,8 +112,14 @@ func (h *UserHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
+	if h.raw == nil {
+		// Local sessions are stateless JWTs; there is nothing server-side to
+		// invalidate, so logout is a client-side no-op for this backend.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-	resp, err := h.service.MakeAuthenticatedRequest("POST", "/logout", nil, accessToken)
+	resp, err := h.raw.MakeAuthenticatedRequest("POST", "/logout", nil, accessToken)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
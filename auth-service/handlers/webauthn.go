@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/middleware"
+	"github.com/Strike-Bet/betting-engine/auth-service/service"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+)
+
+// WebAuthnHandler exposes passkey registration for an already-authenticated
+// user, plus the login ceremony that redeems the mfa_token HandleToken hands
+// back when the account has enrolled credentials.
+type WebAuthnHandler struct {
+	webauthn *service.WebAuthnService
+}
+
+func NewWebAuthnHandler(webauthn *service.WebAuthnService) *WebAuthnHandler {
+	return &WebAuthnHandler{webauthn: webauthn}
+}
+
+// POST /webauthn/register/begin - requires AuthMiddleware
+func (h *WebAuthnHandler) HandleRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	if h.webauthn == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	creation, sessionID, err := h.webauthn.BeginRegistration(user.UserID, user.Email)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to begin registration", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.WebAuthnBeginResponse{SessionID: sessionID, Options: creation})
+}
+
+// POST /webauthn/register/finish?session_id=... - requires AuthMiddleware
+func (h *WebAuthnHandler) HandleRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	if h.webauthn == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Missing authenticated user", "")
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing session_id", "")
+		return
+	}
+
+	if err := h.webauthn.FinishRegistration(user.UserID, sessionID, r); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Registration failed", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /webauthn/login/begin - unauthenticated, gated on the mfa_token
+// HandleToken issued after a successful password check
+func (h *WebAuthnHandler) HandleLoginBegin(w http.ResponseWriter, r *http.Request) {
+	if h.webauthn == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
+	var req types.WebAuthnLoginBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.MFAToken == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing mfa_token", "")
+		return
+	}
+
+	assertion, sessionID, err := h.webauthn.BeginLogin(req.MFAToken)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "Failed to begin login", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.WebAuthnBeginResponse{SessionID: sessionID, Options: assertion})
+}
+
+// POST /webauthn/login/finish?mfa_token=...&session_id=... - unauthenticated,
+// releases the real token pair on a successful assertion
+func (h *WebAuthnHandler) HandleLoginFinish(w http.ResponseWriter, r *http.Request) {
+	if h.webauthn == nil {
+		http.Error(w, "Not supported by the configured auth backend", http.StatusNotImplemented)
+		return
+	}
+
+	mfaToken := r.URL.Query().Get("mfa_token")
+	sessionID := r.URL.Query().Get("session_id")
+	if mfaToken == "" || sessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing mfa_token or session_id", "")
+		return
+	}
+
+	authResp, err := h.webauthn.FinishLogin(mfaToken, sessionID, r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "Login failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResp)
+}
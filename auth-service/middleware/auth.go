@@ -4,9 +4,11 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/Strike-Bet/betting-engine/auth-service/authz"
 	"github.com/Strike-Bet/betting-engine/auth-service/config"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
@@ -20,13 +22,17 @@ const (
 	TokenContextKey contextKey = "token"
 )
 
-// User claims from JWT
+// User claims from JWT. RegisteredClaims.Issuer ("iss") identifies the
+// token's source: empty/Supabase's own issuer for HS256 tokens minted by
+// Supabase, or a configured TrustedIssuer for RS256/ES256 tokens verified
+// against that issuer's JWKS - handlers can branch on it via claims.Issuer.
 type UserClaims struct {
 	UserID   string                 `json:"sub"`
 	Email    string                 `json:"email,omitempty"`
 	Phone    string                 `json:"phone,omitempty"`
 	Role     string                 `json:"role"`
 	Metadata map[string]interface{} `json:"user_metadata,omitempty"`
+	AAL      string                 `json:"aal,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -37,8 +43,10 @@ type ErrorResponse struct {
 	Details string `json:"details,omitempty"`
 }
 
-// Authentication middleware - validates JWT tokens
+// Authentication middleware - validates JWT tokens, accepting Supabase's
+// HS256 tokens as well as RS256/ES256 tokens from any configured TrustedIssuer
 func AuthMiddleware(cfg *config.Config) mux.MiddlewareFunc {
+	jwks := NewJWKSProvider(cfg)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
@@ -57,35 +65,16 @@ func AuthMiddleware(cfg *config.Config) mux.MiddlewareFunc {
 
 			tokenString := tokenParts[1]
 
-			// Parse and validate JWT token
-			token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-				// Validate signing method
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return []byte(cfg.JWTSecret), nil
-			})
-
+			claims, err := parseAndVerify(tokenString, cfg, jwks)
 			if err != nil {
 				writeError(w, http.StatusUnauthorized, "Invalid token: "+err.Error())
 				return
 			}
 
-			if !token.Valid {
-				writeError(w, http.StatusUnauthorized, "Token is not valid")
-				return
-			}
-
-			// Extract claims
-			claims, ok := token.Claims.(*UserClaims)
-			if !ok {
-				writeError(w, http.StatusUnauthorized, "Invalid token claims")
-				return
-			}
-
 			// Add user info to request context
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
 			ctx = context.WithValue(ctx, TokenContextKey, tokenString)
+			stashUserForLogging(w, claims)
 
 			// Continue to next handler
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -93,8 +82,14 @@ func AuthMiddleware(cfg *config.Config) mux.MiddlewareFunc {
 	}
 }
 
-// Admin middleware - requires service role or admin privileges
-func AdminMiddleware(cfg *config.Config) mux.MiddlewareFunc {
+// Admin middleware - requires service role or admin privileges. resolver
+// backs the admin check with the cached, event-invalidated authz.RoleResolver
+// instead of trusting the JWT's own "role" claim, which can go stale between
+// token issuance and revocation; resolver is nil for backends (e.g. "local")
+// that have no public.users table to resolve against, in which case the
+// claim is used as before.
+func AdminMiddleware(cfg *config.Config, resolver authz.RoleResolver) mux.MiddlewareFunc {
+	jwks := NewJWKSProvider(cfg)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
@@ -120,32 +115,22 @@ func AdminMiddleware(cfg *config.Config) mux.MiddlewareFunc {
 				return
 			}
 
-			// Otherwise, validate as regular JWT and check for admin role
-			token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return []byte(cfg.JWTSecret), nil
-			})
-
+			// Otherwise, validate as a regular JWT and check for admin role
+			claims, err := parseAndVerify(tokenString, cfg, jwks)
 			if err != nil {
 				writeError(w, http.StatusForbidden, "Invalid admin token: "+err.Error())
 				return
 			}
 
-			if !token.Valid {
-				writeError(w, http.StatusForbidden, "Admin token is not valid")
-				return
-			}
-
-			claims, ok := token.Claims.(*UserClaims)
-			if !ok {
-				writeError(w, http.StatusForbidden, "Invalid admin token claims")
-				return
-			}
-
-			// Check if user has admin role
-			if claims.Role != "admin" && claims.Role != "service_role" {
+			// Check admin status via the cached resolver where one is
+			// configured, falling back to the JWT's own role claim otherwise.
+			if resolver != nil {
+				isAdmin, err := resolver.IsAdmin(r.Context(), claims.UserID)
+				if err != nil || !isAdmin {
+					writeError(w, http.StatusForbidden, "Admin privileges required")
+					return
+				}
+			} else if claims.Role != "admin" && claims.Role != "service_role" {
 				writeError(w, http.StatusForbidden, "Admin privileges required")
 				return
 			}
@@ -153,12 +138,69 @@ func AdminMiddleware(cfg *config.Config) mux.MiddlewareFunc {
 			// Add user info to request context
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
 			ctx = context.WithValue(ctx, TokenContextKey, tokenString)
+			stashUserForLogging(w, claims)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RequireAAL2 wraps a handler that must already be behind AdminMiddleware (or
+// AuthMiddleware) and rejects any user-token request that was not step-up
+// verified via MFA (i.e. lacks the "aal2" claim minted by MFAService.Verify).
+// Requests authenticated with the raw service role key carry no UserClaims at
+// all and are let through unchanged - the service role key is already the
+// highest trust level this service recognizes.
+func RequireAAL2(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := GetUserFromContext(r)
+		if claims != nil && claims.AAL != "aal2" {
+			writeError(w, http.StatusUnauthorized, "This action requires a verified second factor (AAL2)")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseAndVerify validates tokenString's signature and claims, dispatching on
+// the token's alg header: HS256 is verified against cfg.JWTSecret (Supabase's
+// legacy setup); RS256/ES256 are verified against the JWKS of the TrustedIssuer
+// matching the token's `iss` claim, and its `aud` must match that issuer's
+// configured audience.
+func parseAndVerify(tokenString string, cfg *config.Config, jwks *JWKSProvider) (*UserClaims, error) {
+	claims := &UserClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			return []byte(cfg.JWTSecret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				return nil, fmt.Errorf("token is missing a kid header")
+			}
+			issuer, ok := jwks.Issuer(claims.Issuer)
+			if !ok {
+				return nil, fmt.Errorf("untrusted issuer: %s", claims.Issuer)
+			}
+			if !claims.RegisteredClaims.Audience.Contains(issuer.Audience) {
+				return nil, fmt.Errorf("token audience does not match issuer %s", claims.Issuer)
+			}
+			return jwks.KeyFor(claims.Issuer, kid)
+		default:
+			return nil, jwt.ErrSignatureInvalid
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("token is not valid")
+	}
+
+	return claims, nil
+}
+
 // Helper function to write error responses
 func writeError(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -4,27 +4,130 @@ package middleware
 import (
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
 	"github.com/gorilla/mux"
 )
 
-// CORS middleware
-func CORSMiddleware() mux.MiddlewareFunc {
+// CORSOptions controls CORSMiddleware's behavior for a given router or
+// subrouter, letting individual route groups override the service-wide
+// defaults built from config.Config.
+type CORSOptions struct {
+	AllowedOrigins        []string // exact origins allowed to receive a reflected Access-Control-Allow-Origin
+	AllowedOriginPatterns []string // glob patterns (e.g. "https://*.vercel.app") matched against Origin
+	AllowedMethods        []string
+	AllowedHeaders        []string // header names CORSMiddleware will echo back from Access-Control-Request-Headers
+	AllowCredentials      bool
+	MaxAgeSeconds         int
+
+	compiledPatterns []*regexp.Regexp
+}
+
+// DefaultCORSOptions builds CORSOptions from cfg, the options applied
+// service-wide unless a route group passes its own *CORSOptions.
+func DefaultCORSOptions(cfg *config.Config) *CORSOptions {
+	return &CORSOptions{
+		AllowedOrigins:        cfg.AllowedOrigins,
+		AllowedOriginPatterns: cfg.AllowedOriginPatterns,
+		AllowedMethods:        []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders:        cfg.AllowedHeaders,
+		AllowCredentials:      true,
+		MaxAgeSeconds:         86400,
+	}
+}
+
+// compilePatterns lazily compiles AllowedOriginPatterns (glob syntax, "*"
+// matches any run of characters) into anchored regexps.
+func (o *CORSOptions) compilePatterns() []*regexp.Regexp {
+	if o.compiledPatterns != nil || len(o.AllowedOriginPatterns) == 0 {
+		return o.compiledPatterns
+	}
+	for _, pattern := range o.AllowedOriginPatterns {
+		escaped := regexp.QuoteMeta(pattern)
+		escaped = strings.ReplaceAll(escaped, `\*`, ".*")
+		if re, err := regexp.Compile("^" + escaped + "$"); err == nil {
+			o.compiledPatterns = append(o.compiledPatterns, re)
+		} else {
+			log.Printf("Warning: invalid CORS origin pattern %q, ignoring: %v", pattern, err)
+		}
+	}
+	return o.compiledPatterns
+}
+
+func (o *CORSOptions) originAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range o.AllowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	for _, re := range o.compilePatterns() {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *CORSOptions) headerAllowed(header string) bool {
+	for _, allowed := range o.AllowedHeaders {
+		if strings.EqualFold(header, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRequestHeaders filters the Access-Control-Request-Headers of a
+// preflight request down to the subset configured in AllowedHeaders, rather
+// than blindly echoing a fixed list back.
+func (o *CORSOptions) allowedRequestHeaders(requested string) []string {
+	if requested == "" {
+		return nil
+	}
+	var allowed []string
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && o.headerAllowed(header) {
+			allowed = append(allowed, header)
+		}
+	}
+	return allowed
+}
+
+// CORSMiddleware reflects the request Origin back only when it matches
+// opts' allow-list (exact or glob pattern), omits Access-Control-Allow-Credentials
+// entirely when it doesn't, and always sets Vary: Origin since the response
+// depends on the Origin header either way. Pass nil to use cfg's defaults, or
+// a route-specific *CORSOptions (see DefaultCORSOptions) to override them.
+func CORSMiddleware(cfg *config.Config, opts *CORSOptions) mux.MiddlewareFunc {
+	if opts == nil {
+		opts = DefaultCORSOptions(cfg)
+	}
+	opts.compilePatterns()
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
 
-			// Allow specific origins or all origins
-			if origin == "http://localhost:3000" || origin == "https://localhost:3000" {
+			if opts.originAllowed(origin) {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
-			} else {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
+				if opts.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
 			}
 
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Requested-With, apikey, Accept, Origin")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+			if allowed := opts.allowedRequestHeaders(r.Header.Get("Access-Control-Request-Headers")); len(allowed) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(allowed, ", "))
+			}
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAgeSeconds))
 
 			// Handle preflight requests
 			if r.Method == "OPTIONS" {
@@ -37,32 +140,6 @@ func CORSMiddleware() mux.MiddlewareFunc {
 	}
 }
 
-// Logging middleware
-func LoggingMiddleware() mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("%s %s %s", r.Method, r.RequestURI, r.RemoteAddr)
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
-// Rate limiting middleware (basic implementation)
-func RateLimitMiddleware() mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Add rate limiting headers
-			w.Header().Set("X-RateLimit-Limit", "100")
-			w.Header().Set("X-RateLimit-Remaining", "99")
-			w.Header().Set("X-RateLimit-Reset", "3600")
-
-			// In a real implementation, you'd check rate limits here
-			// For now, just pass through
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 // Security headers middleware
 func SecurityHeadersMiddleware() mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
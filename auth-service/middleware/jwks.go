@@ -0,0 +1,197 @@
+// middleware/jwks.go
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+)
+
+// minRefreshInterval rate-limits how often a single issuer's JWKS endpoint
+// can be re-fetched on a cache-miss, so a flood of tokens with unknown `kid`s
+// can't be used to hammer the issuer.
+const minRefreshInterval = 1 * time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+type issuerKeySet struct {
+	issuer      config.TrustedIssuer
+	mu          sync.Mutex
+	keys        map[string]interface{} // kid -> *rsa.PublicKey | *ecdsa.PublicKey
+	lastFetched time.Time
+}
+
+// JWKSProvider fetches and caches verification keys for a set of trusted
+// external issuers, refreshing an issuer's key set on a cache-miss (bounded
+// by minRefreshInterval) so a new `kid` shows up without a restart.
+type JWKSProvider struct {
+	httpClient *http.Client
+	issuers    map[string]*issuerKeySet // issuer URL -> key set
+}
+
+func NewJWKSProvider(cfg *config.Config) *JWKSProvider {
+	p := &JWKSProvider{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		issuers:    make(map[string]*issuerKeySet),
+	}
+	for _, iss := range cfg.TrustedIssuers {
+		p.issuers[iss.Issuer] = &issuerKeySet{issuer: iss, keys: make(map[string]interface{})}
+	}
+	return p
+}
+
+// Issuer returns the configured TrustedIssuer for iss, if any.
+func (p *JWKSProvider) Issuer(iss string) (config.TrustedIssuer, bool) {
+	set, ok := p.issuers[iss]
+	if !ok {
+		return config.TrustedIssuer{}, false
+	}
+	return set.issuer, true
+}
+
+// KeyFor returns the verification key for (iss, kid), fetching or refreshing
+// the issuer's JWKS document as needed.
+func (p *JWKSProvider) KeyFor(iss, kid string) (interface{}, error) {
+	set, ok := p.issuers[iss]
+	if !ok {
+		return nil, fmt.Errorf("untrusted issuer: %s", iss)
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if key, ok := set.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(set.lastFetched) < minRefreshInterval {
+		return nil, fmt.Errorf("unknown kid %q for issuer %s (refresh rate-limited)", kid, iss)
+	}
+
+	if err := p.refreshLocked(set); err != nil {
+		return nil, err
+	}
+
+	key, ok := set.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q for issuer %s", kid, iss)
+	}
+	return key, nil
+}
+
+func (p *JWKSProvider) refreshLocked(set *issuerKeySet) error {
+	// Stamp the attempt up front so a failing fetch is rate-limited too.
+	set.lastFetched = time.Now()
+
+	resp, err := p.httpClient.Get(set.issuer.JWKSURL)
+	if err != nil {
+		return fmt.Errorf("fetching jwks for %s: %w", set.issuer.Issuer, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks endpoint for %s returned %d", set.issuer.Issuer, resp.StatusCode)
+	}
+
+	var doc jwksDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("decoding jwks for %s: %w", set.issuer.Issuer, err)
+	}
+
+	keys := make(map[string]interface{}, len(doc.Keys))
+	for _, k := range doc.Keys {
+		parsed, err := parseJWK(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = parsed
+	}
+
+	set.keys = keys
+	return nil
+}
+
+func parseJWK(k jwk) (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		return parseRSAJWK(k)
+	case "EC":
+		return parseECJWK(k)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func parseRSAJWK(k jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
+
+func parseECJWK(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported EC curve: %s", k.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}, nil
+}
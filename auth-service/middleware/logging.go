@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader is the header used both to accept a caller-supplied
+// request ID (propagated from an upstream proxy/gateway) and to expose the
+// one LoggingMiddleware generated, so client bug reports are traceable
+// end-to-end.
+const RequestIDHeader = "X-Request-ID"
+
+// redactedFields lists JSON body fields LoggingMiddleware scrubs before
+// logging a request body, since they carry credentials or bearer tokens.
+var redactedFields = []string{"password", "refresh_token", "access_token", "nonce", "token"}
+
+// statusRecorder wraps http.ResponseWriter to capture the status code and
+// byte count written, neither of which is otherwise observable after the
+// handler has run. user is stashed by AuthMiddleware (via stashUserForLogging)
+// once it verifies a token further down the chain, since AuthMiddleware
+// injects UserClaims onto a child request via r.WithContext that only flows
+// downward to handlers - it never reaches back up to the r LoggingMiddleware
+// closed over, so GetUserFromContext(r) here would always be nil otherwise.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	user   *UserClaims
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// stashUserForLogging lets AuthMiddleware hand the claims it just verified
+// back up to LoggingMiddleware for the "sub" log attribute, by unwrapping w
+// down to the *statusRecorder LoggingMiddleware installed. A no-op if w isn't
+// one, e.g. in unit tests that call AuthMiddleware without LoggingMiddleware
+// ahead of it.
+func stashUserForLogging(w http.ResponseWriter, claims *UserClaims) {
+	if rec, ok := w.(*statusRecorder); ok {
+		rec.user = claims
+	}
+}
+
+// LoggingMiddleware replaces the old single-line log.Printf with structured
+// (log/slog) JSON request logs: it generates or propagates an X-Request-ID,
+// records status/bytes/latency, and includes the authenticated sub when
+// AuthMiddleware further down the chain has verified a token (handed back
+// via stashUserForLogging).
+func LoggingMiddleware(cfg *config.Config) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = generateRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			var loggedBody map[string]interface{}
+			if cfg.LogRequestBodies {
+				loggedBody = readAndRedactBody(r)
+			}
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			attrs := []any{
+				"request_id", requestID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"remote_addr", r.RemoteAddr,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"latency_ms", time.Since(start).Milliseconds(),
+			}
+			if rec.user != nil {
+				attrs = append(attrs, "sub", rec.user.UserID)
+			}
+			if loggedBody != nil {
+				attrs = append(attrs, "body", loggedBody)
+			}
+
+			slog.Info("request", attrs...)
+		})
+	}
+}
+
+// readAndRedactBody consumes r.Body to parse it as a flat JSON object,
+// restores it (via a fresh io.NopCloser) so downstream handlers can still
+// read it, and returns a redacted copy for logging. Returns nil for
+// non-object bodies (e.g. empty or malformed requests).
+func readAndRedactBody(r *http.Request) map[string]interface{} {
+	raw, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil
+	}
+
+	return RedactBody(body)
+}
+
+func generateRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// RedactBody returns a copy of body with any of redactedFields' values
+// replaced by "[REDACTED]". It only redacts top-level fields, matching the
+// flat JSON objects this service's request types use; malformed or
+// non-object bodies never reach it, since readAndRedactBody drops them
+// (returns nil) rather than logging them raw.
+func RedactBody(body map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		redacted[k] = v
+	}
+	for _, field := range redactedFields {
+		if _, ok := redacted[field]; ok {
+			redacted[field] = "[REDACTED]"
+		}
+	}
+	return redacted
+}
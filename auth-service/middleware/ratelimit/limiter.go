@@ -0,0 +1,141 @@
+// middleware/ratelimit/limiter.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter is a pluggable token-bucket backend. capacity (burst) tokens are
+// allowed at once, refilling continuously at rps tokens/second, so an
+// in-process map can be swapped for a shared Redis-backed implementation
+// (RedisLimiter) once the service runs behind more than one replica.
+type Limiter interface {
+	// Allow consumes one token from the bucket identified by key. It reports
+	// whether the request is allowed, the tokens remaining, and retryAfter:
+	// for a denied request, how long the caller should wait before its next
+	// token is available; for an allowed one, how long until the bucket is
+	// back to its full burst.
+	Allow(key string, rps float64, burst int) (allowed bool, remaining int, retryAfter time.Duration)
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// InMemoryLimiter is the default Limiter: one token bucket per key, held in
+// process memory. Fine for a single replica; RedisLimiter is needed once the
+// service scales out, since buckets here aren't shared across instances.
+type InMemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewInMemoryLimiter() *InMemoryLimiter {
+	return &InMemoryLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *InMemoryLimiter) Allow(key string, rps float64, burst int) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), lastRefill: time.Now()}
+		l.buckets[key] = b
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = minFloat(float64(burst), b.tokens+elapsed*rps)
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		retryAfter := time.Duration((1-b.tokens)/rps*float64(time.Second)) + time.Second
+		return false, 0, retryAfter
+	}
+
+	b.tokens--
+	retryAfter := time.Duration(float64(burst-int(b.tokens)) / rps * float64(time.Second))
+	return true, int(b.tokens), retryAfter
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// redisTokenBucketScript mirrors InMemoryLimiter's refill math, but performs
+// the read-refill-write atomically in Redis so concurrent replicas share one
+// bucket per key instead of each enforcing its own.
+var redisTokenBucketScript = redis.NewScript(`
+local tokens_key = KEYS[1] .. ":tokens"
+local refill_key = KEYS[1] .. ":refill"
+local rps = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local tokens = tonumber(redis.call("GET", tokens_key))
+local last_refill = tonumber(redis.call("GET", refill_key))
+if tokens == nil then
+	tokens = burst
+	last_refill = now
+end
+
+local elapsed = math.max(0, now - last_refill)
+tokens = math.min(burst, tokens + elapsed * rps)
+
+local allowed = 0
+local retry_after_ms = 0
+if tokens >= 1 then
+	allowed = 1
+	tokens = tokens - 1
+	retry_after_ms = math.floor((burst - math.floor(tokens)) / rps * 1000)
+else
+	retry_after_ms = math.floor((1 - tokens) / rps * 1000) + 1000
+end
+
+redis.call("SET", tokens_key, tokens, "EX", ttl)
+redis.call("SET", refill_key, now, "EX", ttl)
+
+return {allowed, math.floor(tokens), retry_after_ms}
+`)
+
+// RedisLimiter is the shared-state Limiter for multi-replica deployments. It
+// keys buckets the same way InMemoryLimiter does, so switching backends via
+// config.RateLimitBackend doesn't change RouteLimits or key derivation.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+// bucketTTLSeconds bounds how long an idle bucket lingers in Redis; it's well
+// above any realistic refill window so it never expires an active bucket.
+const bucketTTLSeconds = 3600
+
+func (l *RedisLimiter) Allow(key string, rps float64, burst int) (bool, int, time.Duration) {
+	ctx := context.Background()
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+
+	res, err := redisTokenBucketScript.Run(ctx, l.client, []string{key}, rps, burst, now, bucketTTLSeconds).Result()
+	if err != nil {
+		// Fail open: a Redis outage shouldn't take the auth service down with it.
+		return true, burst, 0
+	}
+
+	values := res.([]interface{})
+	allowed := values[0].(int64) == 1
+	remaining := int(values[1].(int64))
+	retryAfter := time.Duration(values[2].(int64)) * time.Millisecond
+	return allowed, remaining, retryAfter
+}
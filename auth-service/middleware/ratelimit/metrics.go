@@ -0,0 +1,16 @@
+// middleware/ratelimit/metrics.go
+package ratelimit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// requestsTotal counts every rate-limited request, labeled by route and
+// outcome ("allowed" or "blocked"), so an operator can graph block rates per
+// endpoint (e.g. a spike on /token means credential stuffing) without
+// scraping logs.
+var requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_ratelimit_requests_total",
+	Help: "Requests seen by the rate limiter, labeled by route and outcome (allowed/blocked).",
+}, []string{"route", "outcome"})
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrInvalidCredential = errors.New("invalid email/phone or password")
+	ErrUserExists        = errors.New("a user with that identifier already exists")
+)
+
+type localUser struct {
+	user         types.User
+	passwordHash []byte
+}
+
+// LocalProvider is a self-contained LoginProvider backend for staging
+// environments, tests, and self-hosted deployments that don't have a
+// Supabase project: passwords are hashed with bcrypt and sessions are signed
+// HS256 JWTs using the same cfg.JWTSecret AuthMiddleware already verifies
+// against. It has no social-login concept, so it does not implement
+// OAuthProvider.
+type LocalProvider struct {
+	config *config.Config
+
+	mu    sync.RWMutex
+	users map[string]*localUser // keyed by email or phone
+}
+
+func NewLocalProvider(cfg *config.Config) *LocalProvider {
+	return &LocalProvider{
+		config: cfg,
+		users:  make(map[string]*localUser),
+	}
+}
+
+func (p *LocalProvider) AttemptLogin(ctx context.Context, identifier, password string) (*types.User, *types.AuthResponse, error) {
+	p.mu.RLock()
+	record, ok := p.users[identifier]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrInvalidCredential
+	}
+
+	if err := bcrypt.CompareHashAndPassword(record.passwordHash, []byte(password)); err != nil {
+		return nil, nil, ErrInvalidCredential
+	}
+
+	authResponse, err := p.issueSession(&record.user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &record.user, authResponse, nil
+}
+
+func (p *LocalProvider) RefreshSession(ctx context.Context, refreshToken string) (*types.User, *types.AuthResponse, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(refreshToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(p.config.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, nil, ErrInvalidGrant
+	}
+
+	sub, _ := claims["sub"].(string)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, record := range p.users {
+		if record.user.ID == sub {
+			authResponse, err := p.issueSession(&record.user)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &record.user, authResponse, nil
+		}
+	}
+	return nil, nil, ErrUserNotFound
+}
+
+func (p *LocalProvider) Signup(ctx context.Context, req types.SignupRequest) (*types.User, error) {
+	identifier := req.Email
+	if identifier == "" {
+		identifier = req.Phone
+	}
+	if identifier == "" {
+		return nil, errors.New("email or phone is required")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.users[identifier]; exists {
+		return nil, ErrUserExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	user := types.User{
+		ID:           id,
+		Aud:          "authenticated",
+		Role:         "authenticated",
+		Email:        req.Email,
+		Phone:        req.Phone,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		UserMetadata: req.Data,
+	}
+	p.users[identifier] = &localUser{user: user, passwordHash: hash}
+
+	return &user, nil
+}
+
+// Recover is a no-op for the local backend: there is no email delivery
+// integration, so a real deployment would wire this to an SMTP/webhook sender.
+func (p *LocalProvider) Recover(ctx context.Context, req types.RecoverRequest) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, ok := p.users[req.Email]; !ok {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// VerifyOTP is unsupported: the local backend has no OTP delivery channel.
+func (p *LocalProvider) VerifyOTP(ctx context.Context, req types.VerifyRequest) (*types.AuthResponse, error) {
+	return nil, errors.New("OTP verification is not supported by the local auth backend")
+}
+
+func (p *LocalProvider) UpdateUser(ctx context.Context, accessToken string, req types.UpdateUserRequest) (*types.User, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(p.config.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidGrant
+	}
+	sub, _ := claims["sub"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for identifier, record := range p.users {
+		if record.user.ID != sub {
+			continue
+		}
+		if req.Email != "" {
+			delete(p.users, identifier)
+			record.user.Email = req.Email
+			identifier = req.Email
+			p.users[identifier] = record
+		}
+		if req.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, err
+			}
+			record.passwordHash = hash
+		}
+		if req.Data != nil {
+			record.user.UserMetadata = req.Data
+		}
+		record.user.UpdatedAt = time.Now()
+		return &record.user, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func (p *LocalProvider) AdminCreateUser(ctx context.Context, req types.AdminUserRequest) (*types.User, error) {
+	identifier := req.Email
+	if identifier == "" {
+		identifier = req.Phone
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	user := types.User{
+		ID:           id,
+		Aud:          "authenticated",
+		Role:         "authenticated",
+		Email:        req.Email,
+		Phone:        req.Phone,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		UserMetadata: req.UserMetadata,
+		AppMetadata:  req.AppMetadata,
+	}
+
+	p.mu.Lock()
+	p.users[identifier] = &localUser{user: user, passwordHash: hash}
+	p.mu.Unlock()
+
+	return &user, nil
+}
+
+// GenerateLink is unsupported: the local backend has no hosted redirect
+// pages to point an action link at.
+func (p *LocalProvider) GenerateLink(ctx context.Context, req types.GenerateLinkRequest) (*types.GenerateLinkResponse, error) {
+	return nil, errors.New("link generation is not supported by the local auth backend")
+}
+
+func (p *LocalProvider) issueSession(user *types.User) (*types.AuthResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(1 * time.Hour)
+
+	claims := jwt.MapClaims{
+		"sub":   user.ID,
+		"email": user.Email,
+		"phone": user.Phone,
+		"role":  user.Role,
+		"iat":   now.Unix(),
+		"exp":   expiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	accessToken, err := token.SignedString([]byte(p.config.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshClaims := jwt.MapClaims{
+		"sub": user.ID,
+		"iat": now.Unix(),
+		"exp": now.Add(30 * 24 * time.Hour).Unix(),
+	}
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(p.config.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.AuthResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt.Unix(),
+		RefreshToken: refreshToken,
+		User:         user,
+		IsAdmin:      user.IsAdmin,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+)
+
+var (
+	ErrFactorNotFound    = errors.New("mfa factor not found")
+	ErrChallengeNotFound = errors.New("mfa challenge not found or expired")
+	ErrInvalidTOTPCode   = errors.New("invalid or expired TOTP code")
+)
+
+const mfaIssuer = "Hoptix"
+
+type mfaFactor struct {
+	types.MFAFactor
+	userID string
+	secret string
+}
+
+type mfaChallenge struct {
+	factorID  string
+	userID    string
+	expiresAt time.Time
+}
+
+// MFAService enrolls and verifies TOTP second factors, and mints "aal2"
+// step-up JWTs on successful verification by re-signing the claims of the
+// access token presented with the verify request - the same re-signing
+// pattern WebAuthnService.stepUpAAL2 uses after a passkey assertion.
+type MFAService struct {
+	config *config.Config
+
+	mu         sync.Mutex
+	factors    map[string]*mfaFactor    // factorID -> factor
+	challenges map[string]*mfaChallenge // challengeID -> challenge
+}
+
+func NewMFAService(cfg *config.Config) *MFAService {
+	return &MFAService{
+		config:     cfg,
+		factors:    make(map[string]*mfaFactor),
+		challenges: make(map[string]*mfaChallenge),
+	}
+}
+
+// Enroll generates a new TOTP secret for userID and stores it, unverified,
+// until the caller completes a challenge/verify round trip.
+func (s *MFAService) Enroll(userID, accountName, friendlyName string) (*types.MFAEnrollResponse, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      mfaIssuer,
+		AccountName: accountName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	factorID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.factors[factorID] = &mfaFactor{
+		MFAFactor: types.MFAFactor{
+			FactorID:     factorID,
+			Type:         "totp",
+			FriendlyName: friendlyName,
+			Verified:     false,
+			CreatedAt:    time.Now(),
+		},
+		userID: userID,
+		secret: key.Secret(),
+	}
+	s.mu.Unlock()
+
+	return &types.MFAEnrollResponse{
+		FactorID:  factorID,
+		Type:      "totp",
+		Secret:    key.Secret(),
+		QRCodeURI: key.URL(),
+	}, nil
+}
+
+// ListFactors returns all factors enrolled by userID
+func (s *MFAService) ListFactors(userID string) []types.MFAFactor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var factors []types.MFAFactor
+	for _, f := range s.factors {
+		if f.userID == userID {
+			factors = append(factors, f.MFAFactor)
+		}
+	}
+	return factors
+}
+
+// DeleteFactor removes a previously enrolled factor
+func (s *MFAService) DeleteFactor(userID, factorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.factors[factorID]
+	if !ok || f.userID != userID {
+		return ErrFactorNotFound
+	}
+	delete(s.factors, factorID)
+	return nil
+}
+
+// Challenge issues a short-lived challenge for factorID, which the caller
+// must complete with Verify within 5 minutes.
+func (s *MFAService) Challenge(userID, factorID string) (*types.MFAChallengeResponse, error) {
+	s.mu.Lock()
+	f, ok := s.factors[factorID]
+	s.mu.Unlock()
+	if !ok || f.userID != userID {
+		return nil, ErrFactorNotFound
+	}
+
+	challengeID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(5 * time.Minute)
+
+	s.mu.Lock()
+	s.challenges[challengeID] = &mfaChallenge{factorID: factorID, userID: userID, expiresAt: expiresAt}
+	s.mu.Unlock()
+
+	return &types.MFAChallengeResponse{ChallengeID: challengeID, ExpiresAt: expiresAt}, nil
+}
+
+// Verify checks the presented TOTP code (allowing +/-1 step of 30s drift)
+// against the challenged factor, marks the factor verified on first success,
+// and mints a fresh "aal2" access token from accessToken's claims.
+func (s *MFAService) Verify(accessToken, factorID, challengeID, code string) (*types.AuthResponse, error) {
+	s.mu.Lock()
+	challenge, ok := s.challenges[challengeID]
+	if ok {
+		delete(s.challenges, challengeID) // single use
+	}
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(challenge.expiresAt) || challenge.factorID != factorID {
+		return nil, ErrChallengeNotFound
+	}
+
+	s.mu.Lock()
+	f, ok := s.factors[factorID]
+	s.mu.Unlock()
+	if !ok || f.userID != challenge.userID {
+		return nil, ErrFactorNotFound
+	}
+
+	valid, err := totp.ValidateCustom(code, f.secret, time.Now(), totp.ValidateOpts{
+		Period:    30,
+		Skew:      1,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil || !valid {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	s.mu.Lock()
+	f.Verified = true
+	s.mu.Unlock()
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid access token")
+	}
+
+	claims["aal"] = "aal2"
+	claims["exp"] = time.Now().Add(1 * time.Hour).Unix()
+	claims["iat"] = time.Now().Unix()
+
+	stepUpToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := stepUpToken.SignedString([]byte(s.config.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := int64(0)
+	if exp, ok := claims["exp"].(int64); ok {
+		expiresAt = exp
+	}
+
+	return &types.AuthResponse{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
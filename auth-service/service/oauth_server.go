@@ -0,0 +1,476 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authorizationCode is a short-lived, single-use code minted by /oauth/authorize
+// and redeemed by /oauth/token. It is bound to the client/redirect pair and the
+// PKCE code_challenge presented at authorization time.
+type authorizationCode struct {
+	ClientID            string
+	RedirectURI         string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	UserID              string
+	AccessToken         string
+	Scopes              string
+	ExpiresAt           time.Time
+}
+
+// refreshTokenRecord tracks what a rotated refresh token is allowed to
+// re-mint: the client-scoped grant (client, user, scope), plus the one piece
+// of the original session's claims (email) that's safe to carry into a
+// delegated token. Each refresh_token grant issues a fresh access token plus
+// a fresh refresh token and invalidates the one just used.
+type refreshTokenRecord struct {
+	ClientID  string
+	UserID    string
+	Scope     string
+	Email     string
+	ExpiresAt time.Time
+}
+
+var (
+	ErrInvalidClient     = errors.New("invalid client_id or redirect_uri")
+	ErrInvalidGrant      = errors.New("invalid or expired authorization code")
+	ErrPKCEVerification  = errors.New("code_verifier does not match code_challenge")
+	ErrUnsupportedMethod = errors.New("unsupported code_challenge_method")
+	ErrInvalidScope      = errors.New("requested scope exceeds what this client is allowed")
+)
+
+// defaultClientScopes is granted to a client registered without an explicit
+// scope, matching what mintIDToken already exposes in an id_token.
+const defaultClientScopes = "openid profile email"
+
+// OAuthServerService implements a first-party OAuth2 authorization server
+// (authorization code + PKCE, RFC 7636) on top of the JWTs this service
+// already issues. It does not replace or call SupabaseAuthService; it signs
+// exchanged access tokens with the same cfg.JWTSecret Supabase signs its own
+// tokens with, so they stay verifiable by AuthMiddleware.
+type OAuthServerService struct {
+	config *config.Config
+
+	// signingKey/keyID sign the OIDC id_tokens minted by mintIDToken. Unlike
+	// the HS256 access/refresh tokens below (signed with the shared
+	// cfg.JWTSecret), id_tokens are RS256 so this service's own
+	// /.well-known/jwks.json (handlers.OpenIDHandler) can publish a genuine
+	// public key for third parties to verify. Loaded from
+	// cfg.OIDCSigningKeyPEM so every replica (and every restart) signs and
+	// publishes the same key; see loadSigningKey for the single-instance
+	// fallback when that's unset.
+	signingKey *rsa.PrivateKey
+	keyID      string
+
+	mu            sync.Mutex
+	clients       map[string]*types.OAuthClient
+	codes         map[string]*authorizationCode
+	refreshTokens map[string]*refreshTokenRecord
+}
+
+func NewOAuthServerService(cfg *config.Config) *OAuthServerService {
+	signingKey, keyID, err := loadSigningKey(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure OIDC id_token signing key: %v", err)
+	}
+
+	return &OAuthServerService{
+		config:        cfg,
+		signingKey:    signingKey,
+		keyID:         keyID,
+		clients:       make(map[string]*types.OAuthClient),
+		codes:         make(map[string]*authorizationCode),
+		refreshTokens: make(map[string]*refreshTokenRecord),
+	}
+}
+
+// SigningPublicKey returns the RSA public key backing OIDC id_tokens and its
+// key ID, for handlers.OpenIDHandler to publish via JWKS.
+func (s *OAuthServerService) SigningPublicKey() (*rsa.PublicKey, string) {
+	return &s.signingKey.PublicKey, s.keyID
+}
+
+// RegisterClient creates a new OAuth client and returns its generated client_id
+func (s *OAuthServerService) RegisterClient(req types.ClientRegistrationRequest) (*types.OAuthClient, error) {
+	clientID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedScopes := req.Scope
+	if allowedScopes == "" {
+		allowedScopes = defaultClientScopes
+	}
+
+	client := &types.OAuthClient{
+		ClientID:      clientID,
+		ClientName:    req.ClientName,
+		RedirectURIs:  req.RedirectURIs,
+		AllowedScopes: allowedScopes,
+		CreatedAt:     time.Now(),
+	}
+
+	s.mu.Lock()
+	s.clients[clientID] = client
+	s.mu.Unlock()
+
+	return client, nil
+}
+
+func (s *OAuthServerService) GetClient(clientID string) (*types.OAuthClient, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, ok := s.clients[clientID]
+	return client, ok
+}
+
+func (s *OAuthServerService) DeleteClient(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, clientID)
+}
+
+// IssueAuthorizationCode validates the client/redirect pair and mints a code
+// bound to the authenticated user's existing access token and PKCE challenge.
+func (s *OAuthServerService) IssueAuthorizationCode(req types.AuthorizeRequest, userID, accessToken string) (string, error) {
+	client, ok := s.GetClient(req.ClientID)
+	if !ok {
+		return "", ErrInvalidClient
+	}
+
+	validRedirect := false
+	for _, uri := range client.RedirectURIs {
+		if uri == req.RedirectURI {
+			validRedirect = true
+			break
+		}
+	}
+	if !validRedirect {
+		return "", ErrInvalidClient
+	}
+
+	if req.CodeChallengeMethod != "S256" && req.CodeChallengeMethod != "plain" {
+		return "", ErrUnsupportedMethod
+	}
+
+	grantedScope, err := grantScope(client.AllowedScopes, req.Scope)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.codes[code] = &authorizationCode{
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		UserID:              userID,
+		AccessToken:         accessToken,
+		Scopes:              grantedScope,
+		ExpiresAt:           time.Now().Add(2 * time.Minute),
+	}
+	s.mu.Unlock()
+
+	return code, nil
+}
+
+// grantScope validates a client's requested scope against what it's allowed
+// (both space-separated scope lists) and returns what to actually grant: an
+// empty request grants the client's full allowed set, otherwise every
+// requested scope must be in it.
+func grantScope(allowedScopes, requestedScope string) (string, error) {
+	if requestedScope == "" {
+		return allowedScopes, nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, scope := range strings.Fields(allowedScopes) {
+		allowed[scope] = true
+	}
+	for _, scope := range strings.Fields(requestedScope) {
+		if !allowed[scope] {
+			return "", ErrInvalidScope
+		}
+	}
+	return requestedScope, nil
+}
+
+// ExchangeAuthorizationCode redeems a code for an access/refresh token pair,
+// verifying the PKCE code_verifier against the stored code_challenge.
+func (s *OAuthServerService) ExchangeAuthorizationCode(req types.TokenExchangeRequest) (*types.AuthResponse, error) {
+	s.mu.Lock()
+	entry, ok := s.codes[req.Code]
+	if ok {
+		delete(s.codes, req.Code) // single use
+	}
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(entry.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+	if entry.ClientID != req.ClientID || entry.RedirectURI != req.RedirectURI {
+		return nil, ErrInvalidGrant
+	}
+	if !verifyPKCE(entry.CodeChallenge, entry.CodeChallengeMethod, req.CodeVerifier) {
+		return nil, ErrPKCEVerification
+	}
+
+	// entry.AccessToken is the user's own session token, captured only to
+	// pull a couple of non-sensitive claims from it below - it is never
+	// handed back to the client. mintTokenPair mints a brand-new token
+	// scoped to entry.Scopes and audienced to the client instead, so a
+	// client that asked for scope=read can't end up with the user's full
+	// session privileges.
+	var email string
+	sourceClaims, sourceErr := s.parseJWT(entry.AccessToken)
+	if sourceErr == nil {
+		email, _ = sourceClaims["email"].(string)
+	}
+
+	resp, err := s.mintTokenPair(entry.ClientID, entry.UserID, entry.Scopes, email)
+	if err != nil {
+		return nil, err
+	}
+
+	// Best-effort: an id_token is an OIDC nicety on top of the access/refresh
+	// pair above, so a claims-parsing hiccup shouldn't fail the exchange.
+	if sourceErr == nil {
+		if idToken, err := s.mintIDToken(entry.ClientID, entry.UserID, sourceClaims); err == nil {
+			resp.IDToken = idToken
+		}
+	}
+
+	return resp, nil
+}
+
+// RefreshAccessToken rotates a refresh token: it mints a fresh, equally
+// client-scoped access token plus a brand-new refresh token, invalidating
+// the one presented.
+func (s *OAuthServerService) RefreshAccessToken(refreshToken string) (*types.AuthResponse, error) {
+	s.mu.Lock()
+	record, ok := s.refreshTokens[refreshToken]
+	if ok {
+		delete(s.refreshTokens, refreshToken)
+	}
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(record.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+
+	return s.mintTokenPair(record.ClientID, record.UserID, record.Scope, record.Email)
+}
+
+// RevokeToken invalidates a refresh token so it can no longer be exchanged
+// for new access tokens (RFC 7009). Revoking an unknown token is a no-op per
+// spec, since the client cannot distinguish "already revoked" from "unknown".
+func (s *OAuthServerService) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.refreshTokens, token)
+}
+
+// mintTokenPair signs a fresh HS256 access token scoped to this grant (not a
+// copy of the user's own session token): aud is the client, scope is the
+// granted scope, and the only claim carried over from the user's session is
+// email, the one mintIDToken and /userinfo already expose to clients anyway.
+func (s *OAuthServerService) mintTokenPair(clientID, userID, scope, email string) (*types.AuthResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(1 * time.Hour)
+	claims := jwt.MapClaims{
+		"sub":   userID,
+		"aud":   clientID,
+		"scope": scope,
+		"iss":   s.config.Issuer,
+		"iat":   now.Unix(),
+		"exp":   expiresAt.Unix(),
+	}
+	if email != "" {
+		claims["email"] = email
+	}
+
+	accessToken, err := s.signJWT(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.refreshTokens[refreshToken] = &refreshTokenRecord{
+		ClientID:  clientID,
+		UserID:    userID,
+		Scope:     scope,
+		Email:     email,
+		ExpiresAt: now.Add(30 * 24 * time.Hour),
+	}
+	s.mu.Unlock()
+
+	return &types.AuthResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt.Unix(),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *OAuthServerService) parseJWT(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(s.config.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+func (s *OAuthServerService) signJWT(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.config.JWTSecret))
+}
+
+// mintIDToken signs an RS256 OIDC id_token for the authorization_code grant,
+// carrying whatever subset of the standard claims the original access token
+// makes available.
+func (s *OAuthServerService) mintIDToken(clientID, userID string, accessClaims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.config.Issuer,
+		"sub": userID,
+		"aud": clientID,
+		"exp": now.Add(1 * time.Hour).Unix(),
+		"iat": now.Unix(),
+	}
+	if email, ok := accessClaims["email"].(string); ok {
+		claims["email"] = email
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.keyID
+	return token.SignedString(s.signingKey)
+}
+
+func verifyPKCE(challenge, method, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	switch method {
+	case "plain":
+		return verifier == challenge
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(sum[:]) == challenge
+	default:
+		return false
+	}
+}
+
+func randomToken(numBytes int) (string, error) {
+	buf := make([]byte, numBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// loadSigningKey returns the RSA key (and its kid) this process should sign
+// OIDC id_tokens with. If cfg.OIDCSigningKeyPEM is configured, it's parsed
+// and paired with cfg.OIDCSigningKeyID (or, if that's unset, a kid derived
+// deterministically from the public key, so replicas sharing the same PEM
+// agree on it without extra configuration). Otherwise a fresh key is
+// generated for this process only, with a loud warning, since the resulting
+// id_tokens won't verify against any other replica or survive a restart.
+func loadSigningKey(cfg *config.Config) (*rsa.PrivateKey, string, error) {
+	if cfg.OIDCSigningKeyPEM == "" {
+		log.Printf("Warning: OIDC_SIGNING_KEY_PEM is not set; generating an ephemeral RS256 id_token signing key for this process. id_tokens issued now will fail JWKS verification after a restart or behind any other replica - set OIDC_SIGNING_KEY_PEM before running more than one instance.")
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, "", fmt.Errorf("generating ephemeral signing key: %w", err)
+		}
+		keyID, err := randomToken(8)
+		if err != nil {
+			return nil, "", fmt.Errorf("generating ephemeral signing key id: %w", err)
+		}
+		return key, keyID, nil
+	}
+
+	block, _ := pem.Decode([]byte(cfg.OIDCSigningKeyPEM))
+	if block == nil {
+		return nil, "", errors.New("OIDC_SIGNING_KEY_PEM is not valid PEM")
+	}
+
+	key, err := parseRSAPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing OIDC_SIGNING_KEY_PEM: %w", err)
+	}
+
+	keyID := cfg.OIDCSigningKeyID
+	if keyID == "" {
+		keyID = fingerprintPublicKey(&key.PublicKey)
+	}
+	return key, keyID, nil
+}
+
+// parseRSAPrivateKey accepts either PKCS1 ("RSA PRIVATE KEY") or PKCS8
+// ("PRIVATE KEY") DER encodings, the two forms openssl and most secret
+// managers produce for RSA keys.
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not an RSA private key")
+	}
+	return key, nil
+}
+
+// fingerprintPublicKey derives a stable kid from pub's DER encoding, so every
+// replica loading the same OIDCSigningKeyPEM publishes the same kid in
+// /.well-known/jwks.json without OIDCSigningKeyID having to be set explicitly.
+func fingerprintPublicKey(pub *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "default"
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
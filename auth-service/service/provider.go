@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+)
+
+// ErrOAuthNotSupported is available to OAuthProvider implementations that
+// cannot serve a third-party social login redirect flow; none of the
+// built-in backends returns it.
+var ErrOAuthNotSupported = errors.New("this auth backend does not support OAuth redirects")
+
+// LoginProvider is implemented by each authentication backend this service
+// can be configured to use (Supabase, a local backend, ...). AuthHandler and
+// UserHandler depend on this interface rather than a concrete backend so the
+// service isn't hard-wired to Supabase.
+type LoginProvider interface {
+	AttemptLogin(ctx context.Context, identifier, password string) (*types.User, *types.AuthResponse, error)
+	RefreshSession(ctx context.Context, refreshToken string) (*types.User, *types.AuthResponse, error)
+	Signup(ctx context.Context, req types.SignupRequest) (*types.User, error)
+	Recover(ctx context.Context, req types.RecoverRequest) error
+	VerifyOTP(ctx context.Context, req types.VerifyRequest) (*types.AuthResponse, error)
+	UpdateUser(ctx context.Context, accessToken string, req types.UpdateUserRequest) (*types.User, error)
+	AdminCreateUser(ctx context.Context, req types.AdminUserRequest) (*types.User, error)
+	GenerateLink(ctx context.Context, req types.GenerateLinkRequest) (*types.GenerateLinkResponse, error)
+}
+
+// OAuthProvider is implemented by each backend capable of brokering a
+// third-party social login redirect flow. Backends that have no concept of
+// social login (e.g. LocalProvider) do not implement it; ProviderRegistry.OAuth
+// then returns nil and OAuthHandler responds 501.
+type OAuthProvider interface {
+	Authorize(ctx context.Context, query map[string][]string) (redirectURL string, err error)
+	Callback(ctx context.Context, query map[string][]string) (*types.AuthResponse, error)
+}
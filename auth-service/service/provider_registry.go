@@ -0,0 +1,46 @@
+package service
+
+// ProviderRegistry resolves a LoginProvider/OAuthProvider by name, letting
+// callers choose a backend per request (e.g. via a `provider` query param or
+// a per-tenant subdomain) instead of a single backend fixed at process
+// startup. A name with no registered provider falls back to defaultName.
+type ProviderRegistry struct {
+	defaultName string
+	logins      map[string]LoginProvider
+	oauths      map[string]OAuthProvider
+}
+
+func NewProviderRegistry(defaultName string) *ProviderRegistry {
+	return &ProviderRegistry{
+		defaultName: defaultName,
+		logins:      make(map[string]LoginProvider),
+		oauths:      make(map[string]OAuthProvider),
+	}
+}
+
+func (r *ProviderRegistry) RegisterLogin(name string, p LoginProvider) {
+	r.logins[name] = p
+}
+
+func (r *ProviderRegistry) RegisterOAuth(name string, p OAuthProvider) {
+	r.oauths[name] = p
+}
+
+// Login resolves name to a LoginProvider, falling back to the registry's
+// default backend when name is empty or unregistered. Returns nil if even
+// the default has no LoginProvider (e.g. an OIDC-only backend).
+func (r *ProviderRegistry) Login(name string) LoginProvider {
+	if p, ok := r.logins[name]; ok {
+		return p
+	}
+	return r.logins[r.defaultName]
+}
+
+// OAuth resolves name to an OAuthProvider, falling back to the registry's
+// default backend when name is empty or unregistered.
+func (r *ProviderRegistry) OAuth(name string) OAuthProvider {
+	if p, ok := r.oauths[name]; ok {
+		return p
+	}
+	return r.oauths[r.defaultName]
+}
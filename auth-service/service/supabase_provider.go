@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/authz"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+)
+
+// SupabaseProvider implements LoginProvider and OAuthProvider on top of the
+// existing SupabaseAuthService HTTP client, so the handlers that used to call
+// MakeRequest directly can instead depend on the provider interfaces.
+type SupabaseProvider struct {
+	client   *SupabaseAuthService
+	resolver authz.RoleResolver
+}
+
+func NewSupabaseProvider(client *SupabaseAuthService, resolver authz.RoleResolver) *SupabaseProvider {
+	return &SupabaseProvider{client: client, resolver: resolver}
+}
+
+func (p *SupabaseProvider) AttemptLogin(ctx context.Context, identifier, password string) (*types.User, *types.AuthResponse, error) {
+	body := struct {
+		Email    string `json:"email,omitempty"`
+		Phone    string `json:"phone,omitempty"`
+		Password string `json:"password"`
+	}{Password: password}
+
+	if isEmail(identifier) {
+		body.Email = identifier
+	} else {
+		body.Phone = identifier
+	}
+
+	resp, err := p.client.MakeRequest("POST", "/token?grant_type=password", body, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	authResponse, err := decodeAuthResponse(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.attachAdminStatus(ctx, authResponse); err != nil {
+		return nil, nil, err
+	}
+
+	return authResponse.User, authResponse, nil
+}
+
+func (p *SupabaseProvider) RefreshSession(ctx context.Context, refreshToken string) (*types.User, *types.AuthResponse, error) {
+	body := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{RefreshToken: refreshToken}
+
+	resp, err := p.client.MakeRequest("POST", "/token?grant_type=refresh_token", body, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	authResponse, err := decodeAuthResponse(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.attachAdminStatus(ctx, authResponse); err != nil {
+		return nil, nil, err
+	}
+
+	return authResponse.User, authResponse, nil
+}
+
+func (p *SupabaseProvider) Signup(ctx context.Context, req types.SignupRequest) (*types.User, error) {
+	resp, err := p.client.MakeRequest("POST", "/signup", req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+
+	var user types.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p *SupabaseProvider) Recover(ctx context.Context, req types.RecoverRequest) error {
+	resp, err := p.client.MakeRequest("POST", "/recover", req, false)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func (p *SupabaseProvider) VerifyOTP(ctx context.Context, req types.VerifyRequest) (*types.AuthResponse, error) {
+	resp, err := p.client.MakeRequest("POST", "/verify", req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeAuthResponse(resp)
+}
+
+func (p *SupabaseProvider) UpdateUser(ctx context.Context, accessToken string, req types.UpdateUserRequest) (*types.User, error) {
+	resp, err := p.client.MakeAuthenticatedRequest("PUT", "/user", req, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+
+	var user types.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p *SupabaseProvider) AdminCreateUser(ctx context.Context, req types.AdminUserRequest) (*types.User, error) {
+	resp, err := p.client.MakeRequest("POST", "/admin/users", req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+
+	var user types.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p *SupabaseProvider) GenerateLink(ctx context.Context, req types.GenerateLinkRequest) (*types.GenerateLinkResponse, error) {
+	resp, err := p.client.MakeRequest("POST", "/admin/generate_link", req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+
+	var linkResp types.GenerateLinkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&linkResp); err != nil {
+		return nil, err
+	}
+	return &linkResp, nil
+}
+
+func (p *SupabaseProvider) Authorize(ctx context.Context, query map[string][]string) (string, error) {
+	endpoint := "/authorize"
+	if encoded := url.Values(query).Encode(); encoded != "" {
+		endpoint += "?" + encoded
+	}
+
+	resp, err := p.client.MakeRequest("GET", endpoint, nil, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusSeeOther || resp.StatusCode == http.StatusFound {
+		return resp.Header.Get("Location"), nil
+	}
+	return "", statusError(resp)
+}
+
+func (p *SupabaseProvider) Callback(ctx context.Context, query map[string][]string) (*types.AuthResponse, error) {
+	endpoint := "/callback"
+	if encoded := url.Values(query).Encode(); encoded != "" {
+		endpoint += "?" + encoded
+	}
+
+	resp, err := p.client.MakeRequest("GET", endpoint, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeAuthResponse(resp)
+}
+
+// attachAdminStatus stamps is_admin onto the auth response via the shared
+// authz.RoleResolver cache, preserving HandleToken's historical behavior
+// without a REST round trip to public.users on every login/refresh.
+func (p *SupabaseProvider) attachAdminStatus(ctx context.Context, authResponse *types.AuthResponse) error {
+	if p.resolver == nil || authResponse.User == nil || authResponse.User.ID == "" {
+		return nil
+	}
+
+	isAdmin, err := p.resolver.IsAdmin(ctx, authResponse.User.ID)
+	if err != nil {
+		return nil // best-effort: a failed admin lookup shouldn't fail the login
+	}
+
+	authResponse.IsAdmin = isAdmin
+	authResponse.User.IsAdmin = isAdmin
+	return nil
+}
+
+func decodeAuthResponse(resp *http.Response) (*types.AuthResponse, error) {
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+
+	var authResponse types.AuthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
+		return nil, err
+	}
+	return &authResponse, nil
+}
+
+// statusError turns a non-2xx Supabase response into a Go error carrying the
+// response body, so callers can surface it without a second round trip.
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("supabase returned %d: %s", resp.StatusCode, string(body))
+}
+
+func isEmail(identifier string) bool {
+	for _, c := range identifier {
+		if c == '@' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,453 @@
+// service/webauthn.go
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/Strike-Bet/betting-engine/auth-service/types"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrWebAuthnNotConfigured = errors.New("webauthn is not available for this auth backend")
+	ErrCeremonyNotFound      = errors.New("webauthn ceremony not found or expired")
+	ErrNoCredentials         = errors.New("user has no enrolled webauthn credentials")
+	ErrMFATokenNotFound      = errors.New("mfa_token not found or expired")
+)
+
+// ceremonyTTL bounds how long a register/login challenge stays valid, same
+// window MFAService.Challenge uses for TOTP challenges.
+const ceremonyTTL = 5 * time.Minute
+
+type registrationCeremony struct {
+	userID    string
+	session   webauthn.SessionData
+	expiresAt time.Time
+}
+
+type loginCeremony struct {
+	userID    string
+	session   webauthn.SessionData
+	expiresAt time.Time
+}
+
+// pendingLogin is the real token pair HandleToken already obtained from the
+// password check, held back until the presented mfa_token is redeemed by a
+// successful /webauthn/login/finish.
+type pendingLogin struct {
+	userID    string
+	auth      *types.AuthResponse
+	expiresAt time.Time
+}
+
+// webauthnUser adapts a Supabase user id/email plus its stored credentials to
+// the webauthn.User interface go-webauthn's ceremonies operate on.
+type webauthnUser struct {
+	id          string
+	email       string
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte                         { return []byte(u.id) }
+func (u *webauthnUser) WebAuthnName() string                       { return u.email }
+func (u *webauthnUser) WebAuthnDisplayName() string                { return u.email }
+func (u *webauthnUser) WebAuthnIcon() string                       { return "" }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// webauthnCredentialRow is the public.webauthn_credentials row shape,
+// persisted and read back via SupabaseAuthService.MakeRestRequest the same
+// way the rest of this package leans on PostgREST instead of a direct DB
+// driver.
+type webauthnCredentialRow struct {
+	ID              string    `json:"id"` // base64url credential ID, primary key
+	UserID          string    `json:"user_id"`
+	PublicKey       string    `json:"public_key"` // base64url COSE public key
+	AttestationType string    `json:"attestation_type"`
+	Transports      []string  `json:"transports"`
+	SignCount       uint32    `json:"sign_count"`
+	AAGUID          string    `json:"aaguid"` // base64url
+	BackupEligible  bool      `json:"backup_eligible"`
+	BackupState     bool      `json:"backup_state"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+}
+
+// WebAuthnService enrolls and verifies passkeys as a second factor layered
+// on top of password login: HandleToken stashes the real token pair behind
+// an opaque mfa_token (see BeginPendingLogin) once a user has enrolled
+// credentials, and FinishLogin releases it only after a successful
+// assertion. Credentials are persisted to a Supabase table, so
+// cmd/api/main.go only constructs the service for the Supabase backend, and
+// handlers.WebAuthnHandler responds 501 when it has none; the ceremony
+// methods return ErrWebAuthnNotConfigured if raw is nil.
+type WebAuthnService struct {
+	config   *config.Config
+	raw      *SupabaseAuthService
+	webauthn *webauthn.WebAuthn
+
+	mu            sync.Mutex
+	registrations map[string]*registrationCeremony // sessionID -> ceremony
+	logins        map[string]*loginCeremony        // sessionID -> ceremony
+	pendingLogins map[string]*pendingLogin         // mfaToken -> pending login
+}
+
+func NewWebAuthnService(cfg *config.Config, raw *SupabaseAuthService) (*WebAuthnService, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPDisplayName,
+		RPOrigins:     cfg.WebAuthnRPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configuring webauthn: %w", err)
+	}
+
+	return &WebAuthnService{
+		config:        cfg,
+		raw:           raw,
+		webauthn:      w,
+		registrations: make(map[string]*registrationCeremony),
+		logins:        make(map[string]*loginCeremony),
+		pendingLogins: make(map[string]*pendingLogin),
+	}, nil
+}
+
+// HasCredentials reports whether userID has at least one enrolled passkey,
+// which is what tells HandleToken's password branch to hold back the real
+// tokens behind an mfa_token instead of returning them directly.
+func (s *WebAuthnService) HasCredentials(userID string) (bool, error) {
+	creds, err := s.credentialsFor(userID)
+	if err != nil {
+		return false, err
+	}
+	return len(creds) > 0, nil
+}
+
+// BeginPendingLogin stashes an already-issued AuthResponse behind a
+// short-lived opaque token, to be released by FinishLogin once the caller
+// completes the WebAuthn ceremony.
+func (s *WebAuthnService) BeginPendingLogin(userID string, auth *types.AuthResponse) (string, error) {
+	mfaToken, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.pendingLogins[mfaToken] = &pendingLogin{userID: userID, auth: auth, expiresAt: time.Now().Add(ceremonyTTL)}
+	s.mu.Unlock()
+
+	return mfaToken, nil
+}
+
+// BeginRegistration starts a "register a new passkey" ceremony for an
+// already-authenticated user (handlers.WebAuthnHandler requires
+// AuthMiddleware ahead of this).
+func (s *WebAuthnService) BeginRegistration(userID, email string) (*protocol.CredentialCreation, string, error) {
+	if s.raw == nil {
+		return nil, "", ErrWebAuthnNotConfigured
+	}
+
+	existing, err := s.credentialsFor(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(&webauthnUser{id: userID, email: email, credentials: existing})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	s.registrations[sessionID] = &registrationCeremony{userID: userID, session: *session, expiresAt: time.Now().Add(ceremonyTTL)}
+	s.mu.Unlock()
+
+	return creation, sessionID, nil
+}
+
+// FinishRegistration validates r's raw PublicKeyCredential body against the
+// ceremony sessionID started and persists the resulting credential.
+func (s *WebAuthnService) FinishRegistration(userID, sessionID string, r *http.Request) error {
+	if s.raw == nil {
+		return ErrWebAuthnNotConfigured
+	}
+
+	s.mu.Lock()
+	ceremony, ok := s.registrations[sessionID]
+	if ok {
+		delete(s.registrations, sessionID) // single use
+	}
+	s.mu.Unlock()
+
+	if !ok || ceremony.userID != userID || time.Now().After(ceremony.expiresAt) {
+		return ErrCeremonyNotFound
+	}
+
+	cred, err := s.webauthn.FinishRegistration(&webauthnUser{id: userID}, ceremony.session, r)
+	if err != nil {
+		return err
+	}
+
+	return s.saveCredential(userID, cred)
+}
+
+// BeginLogin starts the WebAuthn assertion ceremony for the user behind
+// mfaToken (issued by BeginPendingLogin), scoped to that user's own
+// credentials.
+func (s *WebAuthnService) BeginLogin(mfaToken string) (*protocol.CredentialAssertion, string, error) {
+	if s.raw == nil {
+		return nil, "", ErrWebAuthnNotConfigured
+	}
+
+	pending, err := s.peekPendingLogin(mfaToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creds, err := s.credentialsFor(pending.userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(creds) == 0 {
+		return nil, "", ErrNoCredentials
+	}
+
+	assertion, session, err := s.webauthn.BeginLogin(&webauthnUser{id: pending.userID, credentials: creds})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	s.logins[sessionID] = &loginCeremony{userID: pending.userID, session: *session, expiresAt: time.Now().Add(ceremonyTTL)}
+	s.mu.Unlock()
+
+	return assertion, sessionID, nil
+}
+
+// FinishLogin validates r's raw assertion response, updates the credential's
+// sign count, and - only on success - releases the real token pair stashed
+// under mfaToken.
+func (s *WebAuthnService) FinishLogin(mfaToken, sessionID string, r *http.Request) (*types.AuthResponse, error) {
+	if s.raw == nil {
+		return nil, ErrWebAuthnNotConfigured
+	}
+
+	pending, err := s.consumePendingLogin(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	ceremony, ok := s.logins[sessionID]
+	if ok {
+		delete(s.logins, sessionID) // single use
+	}
+	s.mu.Unlock()
+
+	if !ok || ceremony.userID != pending.userID || time.Now().After(ceremony.expiresAt) {
+		return nil, ErrCeremonyNotFound
+	}
+
+	creds, err := s.credentialsFor(pending.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.webauthn.FinishLogin(&webauthnUser{id: pending.userID, credentials: creds}, ceremony.session, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateSignCount(pending.userID, cred); err != nil {
+		return nil, err
+	}
+
+	return s.stepUpAAL2(pending.auth)
+}
+
+// stepUpAAL2 re-signs auth's access token with "aal": "aal2", the same claim
+// MFAService.Verify sets after a successful TOTP check, so a passkey-verified
+// login satisfies middleware.RequireAAL2 just like the TOTP step-up does.
+func (s *WebAuthnService) stepUpAAL2(auth *types.AuthResponse) (*types.AuthResponse, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(auth.AccessToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("parsing access token for step-up: %w", err)
+	}
+
+	claims["aal"] = "aal2"
+	expiresAt := time.Now().Add(1 * time.Hour)
+	claims["exp"] = expiresAt.Unix()
+	claims["iat"] = time.Now().Unix()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWTSecret))
+	if err != nil {
+		return nil, fmt.Errorf("signing step-up token: %w", err)
+	}
+
+	stepped := *auth
+	stepped.AccessToken = signed
+	stepped.ExpiresIn = int(time.Hour.Seconds())
+	stepped.ExpiresAt = expiresAt.Unix()
+	return &stepped, nil
+}
+
+func (s *WebAuthnService) peekPendingLogin(mfaToken string) (*pendingLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pendingLogins[mfaToken]
+	if !ok || time.Now().After(pending.expiresAt) {
+		return nil, ErrMFATokenNotFound
+	}
+	return pending, nil
+}
+
+func (s *WebAuthnService) consumePendingLogin(mfaToken string) (*pendingLogin, error) {
+	s.mu.Lock()
+	pending, ok := s.pendingLogins[mfaToken]
+	if ok {
+		delete(s.pendingLogins, mfaToken) // single use
+	}
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(pending.expiresAt) {
+		return nil, ErrMFATokenNotFound
+	}
+	return pending, nil
+}
+
+// credentialsFor fetches userID's stored passkeys from
+// public.webauthn_credentials via Supabase's PostgREST endpoint, using the
+// service role key the same way handlers.AdminHandler does for privileged
+// operations.
+func (s *WebAuthnService) credentialsFor(userID string) ([]webauthn.Credential, error) {
+	resp, err := s.raw.MakeRestRequest("GET", "/webauthn_credentials?user_id=eq."+userID+"&select=*", nil, s.config.ServiceRoleKey)
+	if err != nil {
+		return nil, fmt.Errorf("fetching webauthn credentials: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("fetching webauthn credentials: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var rows []webauthnCredentialRow
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("decoding webauthn credentials: %w", err)
+	}
+
+	creds := make([]webauthn.Credential, 0, len(rows))
+	for _, row := range rows {
+		cred, err := row.toCredential()
+		if err != nil {
+			continue
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+func (s *WebAuthnService) saveCredential(userID string, cred *webauthn.Credential) error {
+	row := fromCredential(userID, cred)
+
+	resp, err := s.raw.MakeRestRequest("POST", "/webauthn_credentials", row, s.config.ServiceRoleKey)
+	if err != nil {
+		return fmt.Errorf("saving webauthn credential: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("saving webauthn credential: status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *WebAuthnService) updateSignCount(userID string, cred *webauthn.Credential) error {
+	credID := base64.RawURLEncoding.EncodeToString(cred.ID)
+	patch := map[string]interface{}{"sign_count": cred.Authenticator.SignCount}
+
+	resp, err := s.raw.MakeRestRequest("PATCH", "/webauthn_credentials?id=eq."+credID+"&user_id=eq."+userID, patch, s.config.ServiceRoleKey)
+	if err != nil {
+		return fmt.Errorf("updating webauthn sign count: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("updating webauthn sign count: status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (row webauthnCredentialRow) toCredential() (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(row.ID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	publicKey, err := base64.RawURLEncoding.DecodeString(row.PublicKey)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	aaguid, _ := base64.RawURLEncoding.DecodeString(row.AAGUID)
+
+	transports := make([]protocol.AuthenticatorTransport, len(row.Transports))
+	for i, t := range row.Transports {
+		transports[i] = protocol.AuthenticatorTransport(t)
+	}
+
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       publicKey,
+		AttestationType: row.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: row.BackupEligible,
+			BackupState:    row.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    aaguid,
+			SignCount: row.SignCount,
+		},
+	}, nil
+}
+
+func fromCredential(userID string, cred *webauthn.Credential) webauthnCredentialRow {
+	transports := make([]string, len(cred.Transport))
+	for i, t := range cred.Transport {
+		transports[i] = string(t)
+	}
+
+	return webauthnCredentialRow{
+		ID:              base64.RawURLEncoding.EncodeToString(cred.ID),
+		UserID:          userID,
+		PublicKey:       base64.RawURLEncoding.EncodeToString(cred.PublicKey),
+		AttestationType: cred.AttestationType,
+		Transports:      transports,
+		SignCount:       cred.Authenticator.SignCount,
+		AAGUID:          base64.RawURLEncoding.EncodeToString(cred.Authenticator.AAGUID),
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+}
@@ -0,0 +1,112 @@
+// Package session provides the cookie-backed session store behind the
+// browser-facing OAuth2/OIDC login flow in handlers.OAuthHandler: a
+// short-lived "oauth-flow" session carries PKCE state across the
+// /authorize -> identity provider -> /callback redirect, and a longer-lived
+// "auth" session marks the browser as logged in afterwards.
+package session
+
+import (
+	"crypto/rand"
+	"log"
+	"net/http"
+
+	"github.com/Strike-Bet/betting-engine/auth-service/config"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	oauthFlowSessionName = "hoptix_oauth_flow"
+	authSessionName      = "hoptix_auth"
+
+	stateKey        = "state"
+	codeVerifierKey = "code_verifier"
+	redirectKey     = "post_login_redirect"
+	providerKey     = "provider"
+
+	userIDKey = "user_id"
+)
+
+// Store wraps a gorilla/sessions cookie store with the two session shapes
+// this service's OAuth2 login flow needs.
+type Store struct {
+	cookies *sessions.CookieStore
+}
+
+// NewStore builds a Store from cfg.SessionSecret. If unset, a random key is
+// generated for the lifetime of the process - fine for a single instance,
+// but sessions (and any in-flight OAuth redirect) will not survive a
+// restart or be shared across replicas, so production deployments should
+// set SESSION_SECRET explicitly.
+func NewStore(cfg *config.Config) *Store {
+	secret := []byte(cfg.SessionSecret)
+	if len(secret) == 0 {
+		log.Printf("Warning: SESSION_SECRET not set, generating an ephemeral key; sessions will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("failed to generate a session secret: %v", err)
+		}
+	}
+
+	cookies := sessions.NewCookieStore(secret)
+	cookies.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   10 * 60, // 10 minutes; SaveOAuthFlow/StartAuthSession override per-session as needed
+		HttpOnly: true,
+		Secure:   cfg.Environment != "development",
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return &Store{cookies: cookies}
+}
+
+// SaveOAuthFlow stores the state/code_verifier/post_login_redirect/provider
+// for an in-flight authorization-code request in a short-lived, HTTP-only
+// cookie session. provider records which registered OAuthProvider started
+// the flow, so the callback completes against the same backend even if the
+// default changes between requests.
+func (s *Store) SaveOAuthFlow(w http.ResponseWriter, r *http.Request, state, codeVerifier, postLoginRedirect, provider string) error {
+	sess, _ := s.cookies.New(r, oauthFlowSessionName)
+	sess.Options.MaxAge = 10 * 60 // the authorization code round trip should complete within minutes
+	sess.Values[stateKey] = state
+	sess.Values[codeVerifierKey] = codeVerifier
+	sess.Values[redirectKey] = postLoginRedirect
+	sess.Values[providerKey] = provider
+	return sess.Save(r, w)
+}
+
+// ConsumeOAuthFlow reads back the values SaveOAuthFlow stored and clears the
+// cookie (MaxAge=-1) so it can't be replayed against a second callback.
+func (s *Store) ConsumeOAuthFlow(w http.ResponseWriter, r *http.Request) (state, codeVerifier, postLoginRedirect, provider string, ok bool) {
+	sess, err := s.cookies.Get(r, oauthFlowSessionName)
+	if err != nil {
+		return "", "", "", "", false
+	}
+
+	state, _ = sess.Values[stateKey].(string)
+	codeVerifier, _ = sess.Values[codeVerifierKey].(string)
+	postLoginRedirect, _ = sess.Values[redirectKey].(string)
+	provider, _ = sess.Values[providerKey].(string)
+
+	sess.Options.MaxAge = -1
+	sess.Save(r, w)
+
+	return state, codeVerifier, postLoginRedirect, provider, state != "" && codeVerifier != ""
+}
+
+// StartAuthSession marks the browser as logged in as userID.
+func (s *Store) StartAuthSession(w http.ResponseWriter, r *http.Request, userID string) error {
+	sess, _ := s.cookies.New(r, authSessionName)
+	sess.Options.MaxAge = 24 * 60 * 60
+	sess.Values[userIDKey] = userID
+	return sess.Save(r, w)
+}
+
+// ClearAuthSession logs the browser out by expiring the auth session cookie.
+func (s *Store) ClearAuthSession(w http.ResponseWriter, r *http.Request) error {
+	sess, err := s.cookies.Get(r, authSessionName)
+	if err != nil {
+		return nil // no session to clear
+	}
+	sess.Options.MaxAge = -1
+	return sess.Save(r, w)
+}
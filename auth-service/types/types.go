@@ -21,6 +21,7 @@ type User struct {
 	UserMetadata       map[string]interface{} `json:"user_metadata"`
 	AppMetadata        map[string]interface{} `json:"app_metadata"`
 	Identities         []Identity             `json:"identities"`
+	IsAdmin            bool                   `json:"is_admin,omitempty"`
 }
 
 type Identity struct {
@@ -118,6 +119,11 @@ type AuthResponse struct {
 	ExpiresAt    int64  `json:"expires_at"`
 	RefreshToken string `json:"refresh_token"`
 	User         *User  `json:"user"`
+	IsAdmin      bool   `json:"is_admin,omitempty"`
+	// IDToken is only set by OAuthServerService.ExchangeAuthorizationCode -
+	// an RS256-signed OIDC id_token, distinct from the HS256 AccessToken
+	// above, so third parties can verify it against /.well-known/jwks.json.
+	IDToken string `json:"id_token,omitempty"`
 }
 
 type GenerateLinkResponse struct {
@@ -166,3 +172,123 @@ type LoginAdminResponse struct {
 	User         *User             `json:"user"`
 	AdminDetails *AdminUserDetails `json:"admin_details"`
 }
+
+// OAuth2 authorization server types (RFC 6749 / RFC 7636 / RFC 7009)
+
+// AuthorizeRequest models the query parameters of a GET /oauth/authorize request
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope,omitempty"`
+	State               string `json:"state,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// TokenExchangeRequest models the POST /oauth/token body for the
+// authorization_code and refresh_token grants of the local OAuth2 provider
+type TokenExchangeRequest struct {
+	GrantType    string `json:"grant_type"`
+	Code         string `json:"code,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+	ClientID     string `json:"client_id"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// OAuthClient is a registered third-party client of the local authorization server
+type OAuthClient struct {
+	ClientID     string   `json:"client_id"`
+	ClientName   string   `json:"client_name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// AllowedScopes is the space-separated set of scopes this client may be
+	// granted; service.OAuthServerService.IssueAuthorizationCode rejects any
+	// requested scope outside this set instead of silently granting the
+	// authenticated user's full session privileges.
+	AllowedScopes string    `json:"allowed_scopes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ClientRegistrationRequest is the body of POST /oauth/clients
+type ClientRegistrationRequest struct {
+	ClientName   string   `json:"client_name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// Scope is the space-separated set of scopes this client is allowed to
+	// request; left empty, service.OAuthServerService.RegisterClient applies
+	// a conservative default.
+	Scope string `json:"scope,omitempty"`
+}
+
+// RevokeRequest is the body of POST /oauth/revoke (RFC 7009)
+type RevokeRequest struct {
+	Token         string `json:"token"`
+	TokenTypeHint string `json:"token_type_hint,omitempty"`
+}
+
+// MFA (TOTP) types
+
+// MFAEnrollRequest is the body of POST /factors
+type MFAEnrollRequest struct {
+	Type         string `json:"type"` // currently only "totp" is supported
+	FriendlyName string `json:"friendly_name,omitempty"`
+}
+
+// MFAEnrollResponse carries the provisioning details for an authenticator app
+type MFAEnrollResponse struct {
+	FactorID  string `json:"factor_id"`
+	Type      string `json:"type"`
+	Secret    string `json:"secret"`
+	QRCodeURI string `json:"qr_code_uri"`
+}
+
+// MFAFactor describes a previously enrolled factor (GET /factors)
+type MFAFactor struct {
+	FactorID     string    `json:"factor_id"`
+	Type         string    `json:"type"`
+	FriendlyName string    `json:"friendly_name,omitempty"`
+	Verified     bool      `json:"verified"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MFAChallengeRequest is the body of POST /factors/{id}/challenge
+type MFAChallengeRequest struct {
+	FactorID string `json:"factor_id"`
+}
+
+// MFAChallengeResponse is returned by POST /factors/{id}/challenge
+type MFAChallengeResponse struct {
+	ChallengeID string    `json:"challenge_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MFAVerifyRequest is the body of POST /factors/{id}/verify
+type MFAVerifyRequest struct {
+	FactorID    string `json:"factor_id"`
+	ChallengeID string `json:"challenge_id"`
+	Code        string `json:"code"`
+}
+
+// WebAuthn (passkey) types
+
+// WebAuthnBeginResponse wraps a go-webauthn CredentialCreation/CredentialAssertion
+// (encoded as options) with the session_id the caller must echo back to the
+// matching /finish endpoint.
+type WebAuthnBeginResponse struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+// WebAuthnLoginBeginRequest is the body of POST /webauthn/login/begin
+type WebAuthnLoginBeginRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+// MFARequiredResponse is returned by HandleToken's password grant in place
+// of a real token pair when the user has enrolled WebAuthn credentials. The
+// client must complete /webauthn/login/begin and /webauthn/login/finish,
+// presenting MFAToken, to receive the actual AuthResponse.
+type MFARequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}